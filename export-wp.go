@@ -1,134 +1,118 @@
 package main
 
 import (
-	"encoding/xml"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"time"
+	"os/signal"
+	"runtime"
+	"strings"
+	_ "time/tzdata" // for site.timezone on systems without a zoneinfo database
+
+	"github.com/jsilvela/migrate-wp/migrate"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		subcommand := map[string]func([]string) error{"inventory": inventory, "fetch": fetch}[os.Args[1]]
+		if subcommand != nil {
+			err := subcommand(os.Args[2:])
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	var (
-		outdir, xmlFilename string
-		localMedia          string // the url for media the WP site served itself
+		localMedia string // the url for media the WP site served itself
+		cfg        = migrate.DefaultConfig()
 	)
+	cfg.Jobs = runtime.NumCPU()
 
-	flag.StringVar(&outdir, "outdir", "", "name of the output directory")
-	flag.StringVar(&xmlFilename, "xmlfile", "", "name of the input XML file")
-	flag.StringVar(&localMedia, "localmedia", "", "url of the local media section")
-	flag.Parse()
-
-	fmt.Println("flags:", outdir, xmlFilename)
-
-	if len(outdir) == 0 || len(xmlFilename) == 0 {
-		log.Fatalf("flags missing")
+	// the config file is read before the other flags, so they can override it
+	configFile := configFlag(os.Args[1:])
+	if len(configFile) > 0 {
+		err := migrate.LoadConfig(configFile, &cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	// Open our xmlFile
-	xmlFile, err := os.Open(xmlFilename)
-	if err != nil {
-		log.Fatalf("could not open file %s: %v", xmlFilename, err)
+	flag.String("config", "", "YAML config file, see config.example.yaml. Flags override it")
+	flag.StringVar(&cfg.Output.Dir, "outdir", cfg.Output.Dir, "name of the output directory")
+	flag.StringVar(&cfg.Input, "xmlfile", cfg.Input, "name of the input XML file")
+	flag.StringVar(&cfg.Uploads, "uploads", cfg.Uploads,
+		"local copy of wp-content/uploads, to copy the media into the page bundles")
+	flag.StringVar(&cfg.MediaCache, "mediacache", cfg.MediaCache,
+		"dir to download media into from the old site, when there is no -uploads")
+	flag.StringVar(&localMedia, "localmedia", "", "url of the local media section")
+	flag.IntVar(&cfg.Jobs, "jobs", cfg.Jobs, "number of items to process concurrently")
+	if cfg.Statuses == nil {
+		cfg.Statuses = make(map[string]string)
 	}
-
-	fmt.Println("Successfully opened:", xmlFilename)
-	defer xmlFile.Close()
-
-	byteValue, err := ioutil.ReadAll(xmlFile)
-	if err != nil {
-		log.Fatalf("could not read xml: %v", err)
+	for _, status := range []string{"draft", "pending", "private", "future"} {
+		status := status
+		action, found := cfg.Statuses[status]
+		if !found {
+			action = string(migrate.DefaultStatusPolicy().Action(status))
+		}
+		flag.Func(status, fmt.Sprintf("what to do with %s items: %s (default %s)",
+			status, strings.Join(migrate.StatusActionNames(), ", "), action),
+			func(action string) error { cfg.Statuses[status] = action; return nil })
 	}
+	listFlag("types", "comma-separated post types to export", &cfg.Filters.Types)
+	listFlag("skiptypes", "comma-separated post types not to export, replacing the default list; "+
+		"include it to keep skipping those", &cfg.Filters.SkipTypes)
+	flag.StringVar(&cfg.Filters.From, "from", cfg.Filters.From,
+		"export items posted on or after this date, YYYY-MM-DD in GMT")
+	flag.StringVar(&cfg.Filters.To, "to", cfg.Filters.To,
+		"export items posted on or before this date, YYYY-MM-DD in GMT")
+	listFlag("categories", "comma-separated category nicenames; export items in any of them",
+		&cfg.Filters.Categories)
+	listFlag("tags", "comma-separated tag nicenames; export items with any of them", &cfg.Filters.Tags)
+	listFlag("authors", "comma-separated author logins; export items by any of them", &cfg.Filters.Authors)
+	flag.Func("ids", "comma-separated post IDs to export",
+		func(v string) (err error) { cfg.Filters.IDs, err = parseIDList(v); return err })
+	listFlag("redirects", fmt.Sprintf("comma-separated redirect map formats to write, from: %s",
+		strings.Join(migrate.RedirectFormats(), ", ")), &cfg.Output.Redirects)
+	flag.StringVar(&cfg.Output.Links, "links", cfg.Output.Links,
+		"how to rewrite links to other pages of the site: url (default) or relref")
+	flag.StringVar(&cfg.Output.ExcerptField, "excerpt", cfg.Output.ExcerptField,
+		"front matter field for the excerpts (default summary)")
+	flag.BoolVar(&cfg.Output.SplitPages, "splitpages", cfg.Output.SplitPages,
+		"write each page of posts split with <!--nextpage--> as a page of its own")
+	flag.BoolVar(&cfg.Authors.HideLogins, "hidelogins", cfg.Authors.HideLogins,
+		"keep the authors' login names out of the exported site")
+	listFlag("transforms", fmt.Sprintf("comma-separated content transformations, in order, from: %s",
+		strings.Join(migrate.RegisteredTransformers(), ", ")), &cfg.Transforms)
+	flag.Parse()
 
-	var doc rss
-	err = xml.Unmarshal(byteValue, &doc)
+	opts, err := cfg.Options()
 	if err != nil {
-		log.Fatalf("could not parse xml: %v", err)
-	}
-
-	renderer := contentRenderer{
-		transformContent: cleanContent,
+		log.Fatal(err)
 	}
+	fmt.Println("flags:", opts.OutDir, opts.XMLFile)
+	fmt.Println("status policy:", opts.Statuses)
 
-	fmt.Println("read items:", len(doc.Items))
-
-	itemsByKind := make(map[string][]item)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	for _, it := range doc.Items {
-		_, found := itemsByKind[it.PostType]
-		if !found {
-			itemsByKind[it.PostType] = make([]item, 0)
-		}
-		itemsByKind[it.PostType] = append(itemsByKind[it.PostType], it)
+	if closer, ok := opts.Media.(io.Closer); ok {
+		defer closer.Close()
 	}
-
-	for kind, items := range itemsByKind {
-		if kind == "attachment" || kind == "nav_menu_item" {
-			continue
-		}
-		fmt.Println(kind, len(items))
-		err := os.Mkdir(filepath.Join(outdir, kind), 0750)
-		if err != nil {
-			log.Fatalf("could not create dir: %v", err)
-		}
-		fmt.Println("created dir", filepath.Join(outdir, kind))
-		for _, it := range items {
-			if len(it.Slug) == 0 {
-				continue
-			}
-			if it.Status == "trash" || it.Status == "trashed" || it.Status == "draft" {
-				continue
-			}
-			name := it.Slug
-			if kind == "post" {
-				dt, err := time.Parse("2006-01-02 15:04:05", it.PostDate)
-				if err == nil {
-					name = filepath.Join(dt.Format("2006/01/02"), it.Slug)
-				}
-			}
-			err = os.MkdirAll(filepath.Join(outdir, kind, name), 0750)
-			if err != nil {
-				log.Fatalf("could not create dir: %v", err)
-			}
-			fmt.Println("created dir", filepath.Join(outdir, kind, name))
-
-			f, err := os.Create(filepath.Join(outdir, kind, name, "index.md"))
-			if err != nil {
-				log.Fatalf("could not create file: %v", err)
-			}
-			err = renderer.toMarkdown(it, f)
-			if err != nil {
-				log.Println("could not write post: ", err)
-			}
-			err = f.Sync()
-			if err != nil {
-				log.Println("could not flush file: ", err)
-			}
-			err = f.Close()
-			if err != nil {
-				log.Println("could not close file: ", err)
-			}
-
-			if len(it.Comments) > 0 {
-				f, err := os.Create(filepath.Join(outdir, kind, name, "comments.html"))
-				if err != nil {
-					log.Fatalf("could not create file: %v", err)
-				}
-				err = renderer.renderThreads(f, threadComments(it.Comments))
-				if err != nil {
-					log.Println("ayyyyssss", err)
-				}
-				err = f.Sync()
-				if err != nil {
-					log.Println("could not flush file: ", err)
-				}
-				err = f.Close()
-				if err != nil {
-					log.Println("could not close file: ", err)
-				}
-			}
+	opts.Log = os.Stdout
+	err = migrate.Export(ctx, opts)
+	if errs, ok := err.(migrate.ItemErrors); ok {
+		for _, err := range errs {
+			log.Println(err)
 		}
+		log.Fatalf("could not export %d items", len(errs))
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/jsilvela/migrate-wp/migrate"
+)
+
+// fetch is the fetch subcommand: it downloads the media the site uses into
+// the media cache, for exports without a copy of the uploads dir
+func fetch(args []string) error {
+	cfg := migrate.DefaultConfig()
+	if configFile := configFlag(args); len(configFile) > 0 {
+		err := migrate.LoadConfig(configFile, &cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	fs.String("config", "", "YAML config file, see config.example.yaml. Flags override it")
+	fs.StringVar(&cfg.Input, "xmlfile", cfg.Input, "name of the input XML file")
+	fs.StringVar(&cfg.MediaCache, "mediacache", cfg.MediaCache, "dir to download the media into")
+	fs.IntVar(&cfg.Jobs, "jobs", cfg.Jobs, "number of concurrent downloads")
+	if cfg.MaxMediaSize == 0 {
+		cfg.MaxMediaSize = migrate.DefaultMaxMediaSize
+	}
+	if cfg.FetchTimeout == 0 {
+		cfg.FetchTimeout = migrate.DefaultFetchTimeout
+	}
+	fs.Int64Var(&cfg.MaxMediaSize, "maxsize", cfg.MaxMediaSize, "largest file to download, in bytes")
+	fs.DurationVar(&cfg.FetchTimeout, "timeout", cfg.FetchTimeout, "how long a download may take")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if len(cfg.MediaCache) == 0 {
+		return fmt.Errorf("missing -mediacache")
+	}
+	if cfg.Jobs < 1 {
+		return fmt.Errorf("jobs: should be at least 1, got %d", cfg.Jobs)
+	}
+
+	doc, err := migrate.ParseFile(cfg.Input)
+	if err != nil {
+		return err
+	}
+	entries, err := cfg.Site.Inventory(doc.Items, "")
+	if err != nil {
+		return err
+	}
+	var uploadPaths []string
+	for _, entry := range entries {
+		if len(entry.UploadPath) > 0 {
+			uploadPaths = append(uploadPaths, entry.UploadPath)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fetcher := cfg.Fetcher()
+	defer fetcher.Close()
+	stats, err := fetcher.FetchAll(ctx, uploadPaths, os.Stdout)
+	fmt.Printf("%d media files: %d cached, %d fetched, %d missing, %d failed\n",
+		len(uploadPaths), stats.Cached, stats.Fetched, stats.Missing, stats.Failed)
+	return err
+}
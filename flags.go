@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// configFlag finds the value of the -config flag, which has to be known
+// before the rest of the flags are parsed
+func configFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if len(name) == len(arg) || len(arg)-len(name) > 2 {
+			continue
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "config=") {
+			return strings.TrimPrefix(name, "config=")
+		}
+	}
+	return ""
+}
+
+// listFlag defines a flag with a comma-separated value, overriding the list
+func listFlag(name, usage string, list *[]string) {
+	if len(*list) > 0 {
+		usage += fmt.Sprintf(" (default %s)", strings.Join(*list, ","))
+	}
+	flag.Func(name, usage, func(v string) error {
+		*list = parseNames(v)
+		return nil
+	})
+}
+
+// parseNames splits a comma-separated flag value into a list, keeping the order
+func parseNames(value string) []string {
+	var names []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			names = append(names, v)
+		}
+	}
+	return names
+}
+
+// parseIDList splits a comma-separated list of post IDs
+func parseIDList(value string) ([]int, error) {
+	var ids []int
+	for _, v := range parseNames(value) {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("bad post ID %q: %v", v, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
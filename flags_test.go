@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_parseLists(t *testing.T) {
+	names := parseNames(" post, page,,")
+	if strings.Join(names, "|") != "post|page" {
+		t.Errorf("unexpected list: %v", names)
+	}
+
+	ids, err := parseIDList("16, 4516")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 16 || ids[1] != 4516 {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+	if _, err := parseIDList("12,abc"); err == nil {
+		t.Errorf("expected error on bad ID list")
+	}
+}
+
+func Test_configFlag(t *testing.T) {
+	cases := map[string]string{
+		"-outdir out -config site.yaml": "site.yaml",
+		"--config=site.yaml -jobs 2":    "site.yaml",
+		"-outdir config":                "",
+		"-jobs 2 -- -config site.yaml":  "",
+	}
+	for args, expected := range cases {
+		if found := configFlag(strings.Fields(args)); found != expected {
+			t.Errorf("%s: expected config %q, got %q", args, expected, found)
+		}
+	}
+}
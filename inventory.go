@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jsilvela/migrate-wp/migrate"
+)
+
+// inventory is the inventory subcommand: it writes a manifest of the media
+// a site uses, and of the files in the uploads dir it doesn't
+func inventory(args []string) error {
+	cfg := migrate.DefaultConfig()
+	if configFile := configFlag(args); len(configFile) > 0 {
+		err := migrate.LoadConfig(configFile, &cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	fs := flag.NewFlagSet("inventory", flag.ExitOnError)
+	fs.String("config", "", "YAML config file, see config.example.yaml. Flags override it")
+	fs.StringVar(&cfg.Input, "xmlfile", cfg.Input, "name of the input XML file")
+	fs.StringVar(&cfg.Uploads, "uploads", cfg.Uploads, "local copy of wp-content/uploads to check the media against")
+	format := fs.String("format", "csv", "manifest format: csv or json")
+	out := fs.String("out", "", "manifest file, standard output if empty")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	write := map[string]func(io.Writer, []migrate.InventoryEntry) error{
+		"csv":  migrate.WriteInventoryCSV,
+		"json": migrate.WriteInventoryJSON,
+	}[*format]
+	if write == nil {
+		return fmt.Errorf("unknown manifest format %q, want csv or json", *format)
+	}
+
+	doc, err := migrate.ParseFile(cfg.Input)
+	if err != nil {
+		return err
+	}
+	entries, err := cfg.Site.Inventory(doc.Items, cfg.Uploads)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if len(*out) > 0 {
+		w, err = os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("could not create file: %v", err)
+		}
+	}
+	err = write(w, entries)
+	if err != nil {
+		return fmt.Errorf("could not write manifest: %v", err)
+	}
+	if w != os.Stdout {
+		err = w.Close()
+		if err != nil {
+			return fmt.Errorf("could not close file: %v", err)
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		counts[entry.Status]++
+	}
+	fmt.Fprintf(os.Stderr, "%d media files: %d found, %d missing, %d unreferenced, %d unchecked\n", len(entries),
+		counts[migrate.MediaFound], counts[migrate.MediaMissing], counts[migrate.MediaUnreferenced], counts[migrate.MediaUnchecked])
+	return nil
+}
@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Aliases lists the URLs an item could be reached at in the WordPress site,
+// other than its new URL, as paths relative to the site root:
+//   - the path of its permalink
+//   - its guid, if it points into the site
+//   - the ?p=ID, ?page_id=ID or ?attachment_id=ID forms
+//   - the date-based permalinks /YYYY/MM/DD/slug/ and /YYYY/MM/slug/ for posts
+//
+// The redirect maps send all of them to the new URL, see PathAliases for
+// those Hugo can redirect
+func (s Site) Aliases(i Item, newURL string) []string {
+	var aliases []string
+	seen := map[string]bool{normalizePath(newURL): true}
+	add := func(alias string) {
+		if len(alias) == 0 || seen[normalizePath(alias)] {
+			return
+		}
+		seen[normalizePath(alias)] = true
+		aliases = append(aliases, alias)
+	}
+
+	add(s.sitePath(i.Link))
+	add(s.sitePath(i.GUID))
+	if i.ID > 0 {
+		switch i.PostType {
+		case "page":
+			add(fmt.Sprintf("/?page_id=%d", i.ID))
+		case "attachment":
+			add(fmt.Sprintf("/?attachment_id=%d", i.ID))
+		default:
+			add(fmt.Sprintf("/?p=%d", i.ID))
+		}
+	}
+	if i.PostType == "post" && len(i.Slug) > 0 {
+		dt, err := time.Parse(WPDateFormat, i.PostDate)
+		if err == nil {
+			add(path.Join("/", dt.Format("2006/01/02"), i.Slug) + "/")
+			add(path.Join("/", dt.Format("2006/01"), i.Slug) + "/")
+		}
+	}
+	return aliases
+}
+
+// PathAliases are the Aliases without a query, for the front matter: Hugo
+// writes a redirect page for each alias, which no server would find for a
+// query like ?p=ID, and Windows doesn't allow the ? in file names
+func (s Site) PathAliases(i Item, newURL string) []string {
+	var aliases []string
+	for _, alias := range s.Aliases(i, newURL) {
+		if !strings.Contains(alias, "?") {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// sitePath returns the path and query of a URL into the site, or the empty
+// string if the URL points elsewhere
+func (s Site) sitePath(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || !contains(s.Domains, u.Host) {
+		return ""
+	}
+	p := u.EscapedPath()
+	if len(p) == 0 {
+		p = "/"
+	}
+	if len(u.RawQuery) > 0 {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
+
+// normalizePath makes paths comparable regardless of the leading and
+// trailing slashes
+func normalizePath(p string) string {
+	return "/" + strings.Trim(p, "/")
+}
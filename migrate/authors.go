@@ -0,0 +1,183 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	textTpl "text/template"
+)
+
+// TaxonomyAuthors is the Hugo taxonomy for the authors of the items
+const TaxonomyAuthors = "authors"
+
+// AuthorOptions says how the authors of the items are shown
+type AuthorOptions struct {
+	// HideLogins keeps the login names out of the exported site: author
+	// pages are named after the display names, and get no aliases for their
+	// old /author/login/ URLs
+	HideLogins bool `yaml:"hideLogins"`
+	// Profiles override what the export says about an author, by login
+	Profiles map[string]AuthorProfile `yaml:"profiles"`
+}
+
+// AuthorProfile is what the author pages show about an author
+type AuthorProfile struct {
+	Name string `yaml:"name"` // the display name
+	Bio  string `yaml:"bio"`  // markdown
+}
+
+// Author is a user who wrote some of the items
+type Author struct {
+	Login    string
+	Nicename string // the login as in WordPress' author URLs, empty with HideLogins
+	Slug     string // names the author page
+	Name     string
+	Bio      string
+}
+
+// Authors are the authors of a site by login
+type Authors map[string]Author
+
+// nonSlugRegexp matches what is left out of a slug made from a name
+var nonSlugRegexp = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// accents are replaced by the plain letter in slugs, like WordPress does
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "æ", "ae", "ç", "c",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ñ", "n", "ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "œ", "oe",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+)
+
+// slugify makes a slug from a name or title: lower case, without accents,
+// and with dashes between the words
+func slugify(name string) string {
+	return strings.Trim(nonSlugRegexp.ReplaceAllString(accents.Replace(strings.ToLower(name)), "-"), "-")
+}
+
+// NewAuthors collects the authors defined in the channel of an export, and
+// the logins only found in its items. With HideLogins, authors with no
+// display name are left out
+func NewAuthors(doc RSS, opts AuthorOptions) Authors {
+	authors := make(Authors)
+	for _, a := range doc.Authors {
+		name := strings.TrimSpace(a.DisplayName)
+		if len(name) == 0 {
+			name = strings.TrimSpace(a.FirstName + " " + a.LastName)
+		}
+		authors[a.Login] = Author{Login: a.Login, Name: name}
+	}
+	for _, it := range doc.Items {
+		if _, found := authors[it.Author]; !found && len(it.Author) > 0 {
+			authors[it.Author] = Author{Login: it.Author}
+		}
+	}
+	for login, profile := range opts.Profiles {
+		author := authors[login]
+		author.Login = login
+		if len(profile.Name) > 0 {
+			author.Name = profile.Name
+		}
+		author.Bio = profile.Bio
+		authors[login] = author
+	}
+
+	logins := make([]string, 0, len(authors))
+	for login := range authors {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	slugs := make(map[string]bool)
+	for _, login := range logins {
+		author := authors[login]
+		switch {
+		case !opts.HideLogins:
+			author.Nicename = slugify(login)
+			author.Slug = author.Nicename
+			if len(author.Slug) == 0 {
+				author.Slug = "author"
+			}
+			if len(author.Name) == 0 {
+				author.Name = login
+			}
+		case len(author.Name) == 0 || author.Name == login:
+			delete(authors, login)
+			continue
+		default:
+			author.Slug = slugify(author.Name)
+			if len(author.Slug) == 0 {
+				author.Slug = "author"
+			}
+		}
+		// display names need not be unique
+		slug := author.Slug
+		for n := 2; slugs[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", author.Slug, n)
+		}
+		slugs[slug] = true
+		author.Slug = slug
+		authors[login] = author
+	}
+	return authors
+}
+
+// Used are the authors of the items
+func (authors Authors) Used(items []Item) Authors {
+	used := make(Authors)
+	for _, it := range items {
+		if author, found := authors[it.Author]; found {
+			used[it.Author] = author
+		}
+	}
+	return used
+}
+
+// Sorted lists the authors by slug
+func (authors Authors) Sorted() []Author {
+	list := make([]Author, 0, len(authors))
+	for _, author := range authors {
+		list = append(list, author)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Slug < list[j].Slug })
+	return list
+}
+
+// AuthorDir is the directory of an author page, relative to the output dir
+func AuthorDir(author Author) string {
+	return TaxonomyAuthors + "/" + author.Slug
+}
+
+// WriteAuthorPage writes the _index.md of an author page, with the display
+// name and bio, and an alias for the old /author/nicename/ URL unless the
+// login is hidden
+func WriteAuthorPage(writer io.Writer, author Author) error {
+	data := struct {
+		Title, Alias, Bio string
+	}{
+		Title: strconv.Quote(author.Name),
+		Bio:   strings.TrimSpace(author.Bio),
+	}
+	if len(author.Nicename) > 0 {
+		data.Alias = "/author/" + author.Nicename + "/"
+	}
+	err := authorTpl.Execute(writer, data)
+	if err != nil {
+		return fmt.Errorf("could not write author %s: %v", author.Slug, err)
+	}
+	return nil
+}
+
+var authorTpl = textTpl.Must(textTpl.New("author").Parse(`---
+title: {{.Title}}
+{{- with .Alias}}
+aliases: ["{{.}}"]
+{{- end}}
+---
+{{- with .Bio}}
+
+{{.}}
+{{- end}}
+`))
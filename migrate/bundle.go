@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BundleMedia are the media files going into the page bundle of an item,
+// so that its content can refer to them by relative paths
+type BundleMedia struct {
+	files map[string]string // upload path -> name in the bundle
+	names map[string]bool
+}
+
+// NewBundleMedia starts the media of a bundle with no files
+func NewBundleMedia() *BundleMedia {
+	return &BundleMedia{files: make(map[string]string), names: make(map[string]bool)}
+}
+
+// Add puts a media file in the bundle, and gives its name there. Files with
+// the same name from different upload dirs get a numeric suffix
+func (b *BundleMedia) Add(uploadPath string) string {
+	if name, found := b.files[uploadPath]; found {
+		return name
+	}
+	base := path.Base(uploadPath)
+	ext := path.Ext(base)
+	name := base
+	for n := 2; b.names[name] || name == "index.md" || name == "_index.md"; n++ {
+		name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), n, ext)
+	}
+	b.files[uploadPath] = name
+	b.names[name] = true
+	return name
+}
+
+// Files lists the upload paths of the media in the bundle, sorted
+func (b *BundleMedia) Files() []string {
+	files := make([]string, 0, len(b.files))
+	for file := range b.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Name is the name in the bundle of a media file, "" if it is not there
+func (b *BundleMedia) Name(uploadPath string) string {
+	return b.files[uploadPath]
+}
+
+// mediaRefRegexp matches the src and href attributes, with either quote
+var mediaRefRegexp = regexp.MustCompile(`(\s(?:src|href)\s*=\s*)("([^"]*)"|'([^']*)')`)
+
+// bundleMediaRefs points the references to media of the site at copies of
+// the files in the page bundle, when the export copies media
+func bundleMediaRefs(tc TransformContext, content string) string {
+	if tc.Media == nil || tc.Site == nil {
+		return content
+	}
+	return mediaRefRegexp.ReplaceAllStringFunc(content, func(attr string) string {
+		m := mediaRefRegexp.FindStringSubmatch(attr)
+		uploadPath, ok := tc.Site.UploadPath(m[3] + m[4])
+		if !ok {
+			return attr
+		}
+		return m[1] + `"` + tc.Media.Add(uploadPath) + `"`
+	})
+}
+
+// relativeToParent makes the references to bundle media in a page of a
+// split post, which is one level below the bundle, point up to it
+func (b *BundleMedia) relativeToParent(content string) string {
+	return mediaRefRegexp.ReplaceAllStringFunc(content, func(attr string) string {
+		m := mediaRefRegexp.FindStringSubmatch(attr)
+		if !b.names[m[3]+m[4]] {
+			return attr
+		}
+		return m[1] + `"../` + m[3] + m[4] + `"`
+	})
+}
+
+// MissingMedia is a media file an item refers to that the MediaSource
+// doesn't have, or could not give
+type MissingMedia struct {
+	ItemID     int
+	UploadPath string
+	Err        error // why it is unavailable, nil if the source doesn't have it
+}
+
+// mediaCopier copies media files into page bundles, noting the missing
+// ones. It is safe for concurrent use
+type mediaCopier struct {
+	source MediaSource
+
+	mu      sync.Mutex
+	copied  int
+	missing []MissingMedia
+}
+
+// copyAll copies the media of an item into its bundle dir
+func (mc *mediaCopier) copyAll(ctx context.Context, it Item, media *BundleMedia, dir string) error {
+	for _, uploadPath := range media.Files() {
+		err := mc.copy(ctx, uploadPath, filepath.Join(dir, media.Name(uploadPath)))
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrMediaUnavailable) {
+			missing := MissingMedia{ItemID: it.ID, UploadPath: uploadPath}
+			if !errors.Is(err, os.ErrNotExist) {
+				missing.Err = err
+			}
+			mc.mu.Lock()
+			mc.missing = append(mc.missing, missing)
+			mc.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not copy %s: %w", uploadPath, err)
+		}
+		mc.mu.Lock()
+		mc.copied++
+		mc.mu.Unlock()
+	}
+	return nil
+}
+
+func (mc *mediaCopier) copy(ctx context.Context, uploadPath, dst string) error {
+	src, err := mc.source.Open(ctx, uploadPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Missing lists the media files that were not found, by item and path
+func (mc *mediaCopier) Missing() []MissingMedia {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	missing := append([]MissingMedia(nil), mc.missing...)
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].ItemID != missing[j].ItemID {
+			return missing[i].ItemID < missing[j].ItemID
+		}
+		return missing[i].UploadPath < missing[j].UploadPath
+	})
+	return missing
+}
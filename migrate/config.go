@@ -0,0 +1,273 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds everything about a migration, as read from a YAML file.
+// See config.example.yaml for the fields and their meaning
+type Config struct {
+	Input   string `yaml:"input"`   // the WordPress XML export
+	Uploads string `yaml:"uploads"` // local copy of wp-content/uploads, optional
+	// MediaCache is where media are downloaded to from the old site, when
+	// there is no Uploads dir
+	MediaCache string `yaml:"mediaCache"`
+	// MaxMediaSize is the largest media file downloaded, in bytes,
+	// DefaultMaxMediaSize if 0
+	MaxMediaSize int64 `yaml:"maxMediaSize"`
+	// FetchTimeout is how long a media download may take, like 30s,
+	// DefaultFetchTimeout if 0
+	FetchTimeout time.Duration     `yaml:"fetchTimeout"`
+	Output       OutputConfig      `yaml:"output"`
+	Jobs         int               `yaml:"jobs"`
+	Site         Site              `yaml:"site"`
+	Statuses     map[string]string `yaml:"statuses"` // status -> action
+	Filters      FilterConfig      `yaml:"filters"`
+	Transforms   []string          `yaml:"transforms"`
+	Comments     CommentOptions    `yaml:"comments"`
+	Authors      AuthorOptions     `yaml:"authors"`
+	// FrontMatter maps postmeta to front matter fields
+	FrontMatter MetaMapping `yaml:"frontMatter"`
+}
+
+// OutputConfig is the layout of the exported site
+type OutputConfig struct {
+	Dir        string     `yaml:"dir"`
+	Permalinks Permalinks `yaml:"permalinks"` // post type -> pattern
+	Redirects  []string   `yaml:"redirects"`  // redirect map formats
+	Links      string     `yaml:"links"`      // internal links as url or relref
+	// ExcerptField is the front matter field for the excerpts
+	ExcerptField string `yaml:"excerptField"`
+	// SplitPages splits posts at <!--nextpage--> into a page each
+	SplitPages bool `yaml:"splitPages"`
+}
+
+// FilterConfig selects the items to export, see Filter.
+// Dates are YYYY-MM-DD in GMT, both days included
+type FilterConfig struct {
+	Types      []string `yaml:"types"`
+	SkipTypes  []string `yaml:"skipTypes"`
+	From       string   `yaml:"from"`
+	To         string   `yaml:"to"`
+	Categories []string `yaml:"categories"`
+	Tags       []string `yaml:"tags"`
+	Authors    []string `yaml:"authors"`
+	IDs        []int    `yaml:"ids"`
+}
+
+// DefaultConfig has the settings used when there is no config file
+func DefaultConfig() Config {
+	return Config{
+		Output:     OutputConfig{Permalinks: DefaultPermalinks()},
+		Jobs:       1,
+		Site:       DefaultSite(),
+		Filters:    FilterConfig{SkipTypes: []string{"attachment", "nav_menu_item"}},
+		Transforms: append([]string(nil), DefaultSteps...),
+		Comments:   CommentOptions{File: DefaultCommentsFile},
+		// the presets only map the keys of the plugins, if they were used
+		FrontMatter: MetaMapping{Presets: MetaPresetNames()},
+	}
+}
+
+// LoadConfig reads a YAML config file on top of cfg. Fields missing from the
+// file keep their value in cfg, and unknown fields are an error
+func LoadConfig(filename string, cfg *Config) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("could not read config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(contents))
+	dec.KnownFields(true)
+	err = dec.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("could not parse config %s: %w", filename, err)
+	}
+	return nil
+}
+
+// ConfigErrors lists the problems found validating a Config
+type ConfigErrors []string
+
+func (errs ConfigErrors) Error() string {
+	return "invalid config:\n  " + strings.Join(errs, "\n  ")
+}
+
+// Options validates the config and converts it into export Options
+func (c Config) Options() (Options, error) {
+	var errs ConfigErrors
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.Input) == 0 {
+		addErr("input: missing the WordPress XML file")
+	}
+	if len(c.Output.Dir) == 0 {
+		addErr("output.dir: missing the output directory")
+	}
+	if c.Jobs < 1 {
+		addErr("jobs: should be at least 1, got %d", c.Jobs)
+	}
+	if len(c.Site.Domains) == 0 {
+		addErr("site.domains: should list at least one domain")
+	}
+	for i, domain := range append(append([]string(nil), c.Site.Domains...), c.Site.MediaDomains...) {
+		if strings.Contains(domain, "/") {
+			addErr("site: domain #%d %q should be a host name, without scheme or path", i, domain)
+		}
+	}
+	for _, scheme := range c.Site.Schemes {
+		if scheme != "http" && scheme != "https" {
+			addErr("site.schemes: should be http or https, got %q", scheme)
+		}
+	}
+	if c.MaxMediaSize < 0 {
+		addErr("maxMediaSize: should not be negative, got %d", c.MaxMediaSize)
+	}
+	if c.FetchTimeout < 0 {
+		addErr("fetchTimeout: should not be negative, got %s", c.FetchTimeout)
+	}
+	if len(c.Uploads) > 0 {
+		if fi, err := os.Stat(c.Uploads); err != nil || !fi.IsDir() {
+			addErr("uploads: not a directory: %s", c.Uploads)
+		}
+	}
+	if _, err := c.Site.Location(); err != nil {
+		addErr("site.timezone: %v", err)
+	}
+	for i, rw := range c.Site.Rewrites {
+		if len(rw.From) == 0 {
+			addErr("site.rewrites[%d]: missing from", i)
+		}
+	}
+	if err := c.Output.Permalinks.Validate(); err != nil {
+		addErr("output.permalinks: %v", err)
+	}
+	for _, format := range c.Output.Redirects {
+		if len(RedirectsFileName(format)) == 0 {
+			addErr("output.redirects: unknown format %q, want some of %s",
+				format, strings.Join(RedirectFormats(), ", "))
+		}
+	}
+	if len(c.Output.Links) > 0 && c.Output.Links != LinksURL && c.Output.Links != LinksRelref {
+		addErr("output.links: should be %s or %s, got %q", LinksURL, LinksRelref, c.Output.Links)
+	}
+	if strings.ContainsAny(c.Output.ExcerptField, " :\t\n") {
+		addErr("output.excerptField: not a front matter field: %q", c.Output.ExcerptField)
+	}
+	if err := c.FrontMatter.Validate(); err != nil {
+		addErr("frontMatter.presets: %v", err)
+	}
+	if len(c.Comments.File) == 0 && !c.Comments.Skip {
+		addErr("comments.file: missing")
+	}
+
+	statuses := DefaultStatusPolicy()
+	for status, action := range c.Statuses {
+		err := statuses.Set(status, action)
+		if err != nil {
+			addErr("statuses.%s: %v", status, err)
+		}
+	}
+
+	pipeline, err := NewPipeline(c.Transforms...)
+	if err != nil {
+		addErr("transforms: %v, want some of %s", err, strings.Join(RegisteredTransformers(), ", "))
+	}
+
+	filter := Filter{
+		Types:      toSet(c.Filters.Types),
+		SkipTypes:  toSet(c.Filters.SkipTypes),
+		Categories: toSet(c.Filters.Categories),
+		Tags:       toSet(c.Filters.Tags),
+		Authors:    toSet(c.Filters.Authors),
+	}
+	if len(c.Filters.IDs) > 0 {
+		filter.IDs = make(map[int]bool)
+		for _, id := range c.Filters.IDs {
+			filter.IDs[id] = true
+		}
+	}
+	if len(c.Filters.From) > 0 {
+		filter.From, err = ParseDay(c.Filters.From)
+		if err != nil {
+			addErr("filters.from: %v", err)
+		}
+	}
+	if len(c.Filters.To) > 0 {
+		filter.To, err = ParseDay(c.Filters.To)
+		if err != nil {
+			addErr("filters.to: %v", err)
+		}
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	if len(errs) > 0 {
+		return Options{}, errs
+	}
+	site := c.Site
+	var media MediaSource
+	if len(c.Uploads) > 0 {
+		media = UploadsDir(c.Uploads)
+	} else if len(c.MediaCache) > 0 {
+		media = c.Fetcher()
+	}
+	return Options{
+		Media:      media,
+		XMLFile:    c.Input,
+		OutDir:     c.Output.Dir,
+		Permalinks: c.Output.Permalinks,
+		Redirects:  c.Output.Redirects,
+		Links:      c.Output.Links,
+		Excerpt:    c.Output.ExcerptField,
+		SplitPages: c.Output.SplitPages,
+		Statuses:   statuses,
+		Filter:     filter,
+		Jobs:       c.Jobs,
+		Site:       &site,
+		Pipeline:   pipeline,
+		Comments:   c.Comments,
+		Authors:    c.Authors,
+		Meta:       c.FrontMatter,
+	}, nil
+}
+
+// Fetcher downloads media into the MediaCache
+func (c Config) Fetcher() *Fetcher {
+	return &Fetcher{
+		Dir:      c.MediaCache,
+		BaseURLs: c.Site.UploadBaseURLs(),
+		Jobs:     c.Jobs,
+		MaxSize:  c.MaxMediaSize,
+		Timeout:  c.FetchTimeout,
+	}
+}
+
+// ParseDay reads a YYYY-MM-DD date, as UTC like PostDateGMT
+func ParseDay(value string) (time.Time, error) {
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad date %q, want YYYY-MM-DD", value)
+	}
+	return day, nil
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"sync"
+	"time"
+)
+
+// zeroWPDate is how WordPress writes the GMT dates of unpublished items
+const zeroWPDate = "0000-00-00 00:00:00"
+
+// locations caches the time zones loaded by Site.Location
+var locations sync.Map
+
+// Location is the site's time zone, UTC if Timezone is empty
+func (s Site) Location() (*time.Location, error) {
+	if len(s.Timezone) == 0 {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(s.Timezone); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(s.Timezone, loc)
+	return loc, nil
+}
+
+// wpTime reads a pair of WordPress dates: the GMT one if it is set, the
+// local one otherwise, which is in the site's time zone. The result is in
+// the site's time zone, and zero if neither date can be read
+func (s Site) wpTime(gmt, local string) time.Time {
+	loc, err := s.Location()
+	if err != nil {
+		loc = time.UTC
+	}
+	if len(gmt) > 0 && gmt != zeroWPDate {
+		t, err := time.Parse(WPDateFormat, gmt)
+		if err == nil {
+			return t.In(loc)
+		}
+	}
+	t, err := time.ParseInLocation(WPDateFormat, local, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// PostTime is when an item was posted, in the site's time zone. Items
+// without wp:post_date fields fall back to the RSS pubDate
+func (s Site) PostTime(i Item) time.Time {
+	t := s.wpTime(i.PostDateGMT, i.PostDate)
+	if t.IsZero() {
+		pub, err := time.Parse(time.RFC1123Z, i.PubDate)
+		if err != nil {
+			return t
+		}
+		loc, err := s.Location()
+		if err != nil {
+			loc = time.UTC
+		}
+		t = pub.In(loc)
+	}
+	return t
+}
+
+// ModifiedTime is when an item was last modified, in the site's time zone,
+// or zero if the export doesn't say
+func (s Site) ModifiedTime(i Item) time.Time {
+	return s.wpTime(i.PostModifiedGMT, i.PostModified)
+}
+
+// CommentTime is when a comment was written, in the site's time zone
+func (s Site) CommentTime(c Comment) time.Time {
+	return s.wpTime(c.CommentDateGMT, c.CommentDate)
+}
+
+// isoDate formats a time as ISO 8601, or "" if it is zero
+func isoDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
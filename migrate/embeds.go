@@ -0,0 +1,143 @@
+package migrate
+
+import (
+	"crypto/md5"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultEmbeds maps the oEmbed providers that have a Hugo shortcode to it.
+// Hugo 0.141 and later also call the tweet shortcode x
+var DefaultEmbeds = map[string]string{
+	"youtube": "youtube",
+	"vimeo":   "vimeo",
+	"twitter": "tweet",
+}
+
+// embedProvider recognizes the URLs of an oEmbed provider, and writes the
+// arguments of its shortcode from the match
+type embedProvider struct {
+	name string
+	re   *regexp.Regexp
+	args func(m []string) string
+}
+
+var embedProviders = []embedProvider{
+	{
+		name: "youtube",
+		re:   regexp.MustCompile(`^https?://(?:(?:www\.|m\.)?youtube\.com/(?:watch\?(?:[^#]*&)?v=|embed/|shorts/|v/)|youtu\.be/)([\w-]{11})\b`),
+		args: func(m []string) string { return m[1] },
+	},
+	{
+		name: "vimeo",
+		re:   regexp.MustCompile(`^https?://(?:www\.|player\.)?vimeo\.com/(?:video/)?(\d+)\b`),
+		args: func(m []string) string { return m[1] },
+	},
+	{
+		name: "twitter",
+		re:   regexp.MustCompile(`^https?://(?:www\.|mobile\.)?(?:twitter|x)\.com/(\w+)/status(?:es)?/(\d+)\b`),
+		args: func(m []string) string { return fmt.Sprintf(`user="%s" id="%s"`, m[1], m[2]) },
+	},
+}
+
+var (
+	// embedLineRegexp matches URLs on a line of their own, maybe in a
+	// paragraph of their own, which WordPress embeds
+	embedLineRegexp = regexp.MustCompile(`(?im)^[ \t]*(?:<p(?:\s[^>]*)?>[ \t]*)?(https?://[^\s<>"]+?)(?:[ \t]*</p>)?[ \t\r]*$`)
+	// preformattedRegexp matches the <pre> and <code> blocks, where WordPress
+	// doesn't embed URLs
+	preformattedRegexp = regexp.MustCompile(`(?is)<pre\b.*?</pre>|<code\b.*?</code>`)
+	// embedShortcodeRegexp matches the [embed] shortcode
+	embedShortcodeRegexp = regexp.MustCompile(`\[embed(?:\s[^\]]*)?\]\s*([^\s\[\]]+)\s*\[/embed\]`)
+)
+
+// oembedUnknown is cached by WordPress for URLs it could not embed
+const oembedUnknown = "{{unknown}}"
+
+// convertEmbeds turns the URLs WordPress would embed into the Hugo
+// shortcode of their provider, see Site.Embeds. URLs without a shortcode get
+// the oEmbed HTML WordPress cached in the item's postmeta, if there is any,
+// and are left alone otherwise. URLs in <pre> and <code> blocks are not
+// embedded. Comments don't have embeds
+func convertEmbeds(tc TransformContext, content string) string {
+	if tc.Comment != nil || tc.Item == nil {
+		return content
+	}
+	shortcodes := DefaultEmbeds
+	if tc.Site != nil && tc.Site.Embeds != nil {
+		shortcodes = tc.Site.Embeds
+	}
+	embed := func(match string, rawURL string) string {
+		u := html.UnescapeString(rawURL)
+		for _, p := range embedProviders {
+			m := p.re.FindStringSubmatch(u)
+			if m != nil && len(shortcodes[p.name]) > 0 {
+				return fmt.Sprintf("{{< %s %s >}}", shortcodes[p.name], p.args(m))
+			}
+		}
+		if cached, found := tc.Item.OEmbed(u); found {
+			return cached
+		}
+		return match
+	}
+
+	content = embedShortcodeRegexp.ReplaceAllStringFunc(content, func(match string) string {
+		return embed(match, embedShortcodeRegexp.FindStringSubmatch(match)[1])
+	})
+	blocks := preformattedRegexp.FindAllStringIndex(content, -1)
+	var b strings.Builder
+	last := 0
+	for _, m := range embedLineRegexp.FindAllStringSubmatchIndex(content, -1) {
+		if overlaps(m[0], m[1], blocks) {
+			continue
+		}
+		b.WriteString(content[last:m[0]])
+		b.WriteString(embed(content[m[0]:m[1]], content[m[2]:m[3]]))
+		last = m[1]
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// overlaps tells if the span from start to end overlaps any of the spans
+func overlaps(start, end int, spans [][]int) bool {
+	for _, span := range spans {
+		if start < span[1] && span[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// oembedKey is the postmeta key WordPress caches the oEmbed HTML of a URL
+// under, for the default embed size
+func oembedKey(u string) string {
+	attr := `a:2:{s:5:"width";i:500;s:6:"height";i:750;}`
+	return fmt.Sprintf("_oembed_%x", md5.Sum([]byte(u+attr)))
+}
+
+// OEmbed finds the oEmbed HTML WordPress cached for a URL in the item. The
+// cache is keyed by a hash of the URL and the embed size, which depends on
+// the theme, so without the default size it settles for HTML that mentions
+// the URL
+func (i Item) OEmbed(u string) (string, bool) {
+	if cached, found := i.Meta(oembedKey(u)); found && cached != oembedUnknown && len(strings.TrimSpace(cached)) > 0 {
+		return cached, true
+	}
+	for _, meta := range i.PostMeta {
+		cached := meta.MetaValue.Value
+		if !strings.HasPrefix(meta.MetaKey, "_oembed_") || strings.HasPrefix(meta.MetaKey, "_oembed_time_") ||
+			cached == oembedUnknown {
+			continue
+		}
+		for _, mention := range []string{u, html.EscapeString(u), url.QueryEscape(u)} {
+			if strings.Contains(cached, mention) {
+				return cached, true
+			}
+		}
+	}
+	return "", false
+}
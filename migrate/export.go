@@ -0,0 +1,426 @@
+package migrate
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Options configures an Export
+type Options struct {
+	XMLFile  string       // the WordPress export to read
+	OutDir   string       // where to write the Hugo content
+	Statuses StatusPolicy // DefaultStatusPolicy if nil
+	Filter   Filter       // which items to export
+	Jobs     int          // items processed concurrently, at least 1
+	Site     *Site        // DefaultSite if nil
+	Pipeline Pipeline     // applied to content and comments, DefaultPipeline if nil
+	// Permalinks lay out the items, both their URLs and their directories,
+	// DefaultPermalinks if nil
+	Permalinks Permalinks
+	// Redirects lists the RedirectFormats to write redirect maps in, from
+	// the old URLs to the new ones
+	Redirects []string
+	// Links is how internal links are rewritten: LinksURL (the default) or
+	// LinksRelref
+	Links string
+	// Excerpt is the front matter field for the excerpts,
+	// DefaultExcerptField if empty
+	Excerpt string
+	// SplitPages writes each page of a post split with <!--nextpage--> as a
+	// page bundle of its own, see Layout.PartDir
+	SplitPages bool
+	Comments   CommentOptions
+	Authors    AuthorOptions
+	Meta       MetaMapping // postmeta for the front matter, none if zero
+	// Media has the files to copy into the page bundles, where the content
+	// refers to them by relative paths. Media are left where they are if nil
+	Media MediaSource
+	// Log receives the progress messages, which are discarded if nil
+	Log io.Writer
+}
+
+// CommentOptions says how comment threads are written
+type CommentOptions struct {
+	Skip bool   `yaml:"skip"` // don't write the comments
+	File string `yaml:"file"` // file name in the page bundle, DefaultCommentsFile if empty
+}
+
+const (
+	// DefaultCommentsFile is the file in the page bundle with the comments
+	DefaultCommentsFile = "comments.html"
+)
+
+// ItemErrors lists the items that could not be exported
+type ItemErrors []error
+
+func (errs ItemErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("could not export %d items: %s", len(errs), strings.Join(msgs, "; "))
+}
+
+// ParseFile reads a WordPress XML export
+func ParseFile(filename string) (RSS, error) {
+	var doc RSS
+	xmlFile, err := os.Open(filename)
+	if err != nil {
+		return doc, fmt.Errorf("could not open file %s: %w", filename, err)
+	}
+	defer xmlFile.Close()
+
+	byteValue, err := ioutil.ReadAll(xmlFile)
+	if err != nil {
+		return doc, fmt.Errorf("could not read xml: %w", err)
+	}
+
+	err = xml.Unmarshal(byteValue, &doc)
+	if err != nil {
+		return doc, fmt.Errorf("could not parse xml: %w", err)
+	}
+	return doc, nil
+}
+
+// Export converts the items in a WordPress XML export into Hugo page
+// bundles: a directory per item with an index.md file (_index.md for pages
+// with children) and, if the item has
+// comments, a comments.html file. The categories, tags and authors of the
+// items get term pages with their display names.
+// If some items can't be written the rest are still exported, and the error
+// is an ItemErrors. Cancelling the context stops the export
+func Export(ctx context.Context, opts Options) error {
+	logOut := opts.Log
+	if logOut == nil {
+		logOut = ioutil.Discard
+	}
+	statuses := opts.Statuses
+	if statuses == nil {
+		statuses = DefaultStatusPolicy()
+	}
+	renderer := ContentRenderer{
+		Pipeline: opts.Pipeline,
+		Site:     DefaultSite(),
+		Statuses: statuses,
+
+		ExcerptField: opts.Excerpt,
+		Meta:         opts.Meta,
+	}
+	if renderer.Pipeline == nil {
+		renderer.Pipeline = DefaultPipeline()
+	}
+	if opts.Site != nil {
+		renderer.Site = *opts.Site
+	}
+	if opts.Permalinks == nil {
+		opts.Permalinks = DefaultPermalinks()
+	}
+	err := opts.Permalinks.Validate()
+	if err != nil {
+		return err
+	}
+	err = opts.Meta.Validate()
+	if err != nil {
+		return err
+	}
+	if len(opts.Comments.File) == 0 {
+		opts.Comments.File = DefaultCommentsFile
+	}
+
+	doc, err := ParseFile(opts.XMLFile)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(logOut, "read items:", len(doc.Items))
+
+	fillSlugs(doc.Items)
+	kindCounts := make(map[string]int)
+	var items []Item
+	for _, it := range doc.Items {
+		if !opts.Filter.Match(it) || len(it.Slug) == 0 || statuses.Action(it.Status) == ActionSkip {
+			continue
+		}
+		kindCounts[it.PostType]++
+		items = append(items, it)
+	}
+	kinds := make([]string, 0, len(kindCounts))
+	for kind := range kindCounts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintln(logOut, kind, kindCounts[kind])
+	}
+
+	if len(opts.Links) == 0 {
+		opts.Links = LinksURL
+	}
+	renderer.Authors = NewAuthors(doc, opts.Authors)
+	taxonomies := NewTaxonomies(doc)
+	layout, warnings := NewLayout(opts.Permalinks, statuses, renderer.Authors, taxonomies.Categories, items)
+	for _, warning := range warnings {
+		fmt.Fprintln(logOut, "WARN:", warning)
+	}
+	renderer.Layout = layout
+	// private items must not be reachable from the public site
+	public := filterItems(items, func(it Item) bool { return statuses.Action(it.Status) != ActionPrivate })
+	renderer.Attachments = NewAttachments(doc.Items)
+	renderer.Originals = renderer.Attachments.UploadPaths(renderer.Site)
+	renderer.Links, err = NewLinkIndex(renderer.Site, public, renderer.Attachments, layout.URL, layout.ContentFile, opts.Links)
+	if err != nil {
+		return err
+	}
+
+	var copier *mediaCopier
+	if opts.Media != nil {
+		copier = &mediaCopier{source: opts.Media}
+	}
+	errs := runPool(ctx, opts.Jobs, len(items), func(i int, logger *log.Logger) error {
+		r := renderer
+		r.Logger = logger
+		if copier != nil {
+			r.Media = NewBundleMedia()
+		}
+		err := exportItem(ctx, r, opts, items[i], copier, logger)
+		if err != nil {
+			return fmt.Errorf("%s %q (ID %d): %w", items[i].PostType, items[i].Slug, items[i].ID, err)
+		}
+		return nil
+	}, logOut)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	terms := taxonomies.Used(public)
+	for _, taxonomy := range []struct {
+		name  string
+		terms Terms
+	}{{TaxonomyCategories, terms.Categories}, {TaxonomyTags, terms.Tags}} {
+		err := writeTermPages(opts.OutDir, taxonomy.name, taxonomy.terms)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(logOut, "wrote", len(taxonomy.terms), taxonomy.name)
+	}
+
+	authors := renderer.Authors.Used(public)
+	err = writeAuthorPages(opts.OutDir, authors)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(logOut, "wrote", len(authors), TaxonomyAuthors)
+
+	if copier != nil {
+		fmt.Fprintln(logOut, "copied", copier.copied, "media files")
+		for _, mm := range copier.Missing() {
+			if mm.Err != nil {
+				fmt.Fprintf(logOut, "WARN: unavailable media in item %d: %s: %v\n", mm.ItemID, mm.UploadPath, mm.Err)
+				continue
+			}
+			fmt.Fprintf(logOut, "WARN: missing media in item %d: %s\n", mm.ItemID, mm.UploadPath)
+		}
+	}
+
+	for _, bl := range renderer.Links.Unresolved() {
+		fmt.Fprintf(logOut, "WARN: unresolved link in item %d: %s\n", bl.ItemID, bl.Href)
+	}
+
+	if len(opts.Redirects) > 0 {
+		redirects := append(renderer.Site.mediaRedirects(), terms.termRedirects()...)
+		// only published items are built by Hugo, the rest would be a 404
+		for _, it := range filterItems(public, func(it Item) bool { return statuses.Action(it.Status) == ActionPublish }) {
+			redirects = append(redirects, renderer.Site.itemRedirects(it, layout.URL(it))...)
+		}
+		redirects = uniqueRedirects(redirects)
+		for _, format := range opts.Redirects {
+			err := writeRedirectsFile(filepath.Join(opts.OutDir, RedirectsFileName(format)), format, redirects)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(logOut, "wrote", len(redirects), format, "redirects")
+		}
+	}
+
+	if len(errs) > 0 {
+		return ItemErrors(errs)
+	}
+	return nil
+}
+
+// filterItems returns the items for which keep is true
+func filterItems(items []Item, keep func(Item) bool) []Item {
+	var kept []Item
+	for _, it := range items {
+		if keep(it) {
+			kept = append(kept, it)
+		}
+	}
+	return kept
+}
+
+// writeAuthorPages writes an _index.md for each author
+func writeAuthorPages(outDir string, authors Authors) error {
+	for _, author := range authors.Sorted() {
+		author := author
+		err := writeIndexFile(filepath.Join(outDir, filepath.FromSlash(AuthorDir(author))),
+			func(w io.Writer) error { return WriteAuthorPage(w, author) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTermPages writes an _index.md for each term of a taxonomy
+func writeTermPages(outDir, taxonomy string, terms Terms) error {
+	for _, term := range terms.Sorted() {
+		term := term
+		err := writeIndexFile(filepath.Join(outDir, filepath.FromSlash(TermDir(taxonomy, term))),
+			func(w io.Writer) error { return terms.WriteTermPage(w, taxonomy, term) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIndexFile creates a dir with an _index.md written by write
+func writeIndexFile(dir string, write func(io.Writer) error) error {
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return fmt.Errorf("could not create dir: %v", err)
+	}
+	f, err := os.Create(filepath.Join(dir, "_index.md"))
+	if err != nil {
+		return fmt.Errorf("could not create file: %v", err)
+	}
+	err = write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("could not close file: %v", err)
+	}
+	return nil
+}
+
+func writeRedirectsFile(filename, format string, redirects []Redirect) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("could not create file: %v", err)
+	}
+	err = WriteRedirects(f, format, redirects)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not write redirects: %v", err)
+	}
+	return f.Close()
+}
+
+// exportItem writes an item to its directory under the output dir, as an
+// index.md or _index.md file, plus the comments file if it has comments and,
+// when copying media, the media it refers to and its attachments
+func exportItem(ctx context.Context, renderer ContentRenderer, opts Options, it Item,
+	copier *mediaCopier, logger *log.Logger) error {
+	dir := filepath.Join(opts.OutDir, renderer.Layout.Dir(it))
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return fmt.Errorf("could not create dir: %v", err)
+	}
+	logger.Println("created dir", dir)
+
+	if opts.SplitPages {
+		err = exportPages(renderer, opts, it, logger)
+		if err != nil {
+			return err
+		}
+	} else {
+		f, err := os.Create(filepath.Join(dir, renderer.Layout.IndexFile(it)))
+		if err != nil {
+			return fmt.Errorf("could not create file: %v", err)
+		}
+		err = renderer.ToMarkdown(it, f)
+		if err != nil {
+			logger.Println("could not write post: ", err)
+		}
+		err = f.Sync()
+		if err != nil {
+			logger.Println("could not flush file: ", err)
+		}
+		err = f.Close()
+		if err != nil {
+			logger.Println("could not close file: ", err)
+		}
+	}
+
+	if len(it.Comments) > 0 && !opts.Comments.Skip {
+		f, err := os.Create(filepath.Join(dir, opts.Comments.File))
+		if err != nil {
+			return fmt.Errorf("could not create file: %v", err)
+		}
+		err = renderer.RenderThreads(f, it, ThreadComments(it.Comments))
+		if err != nil {
+			logger.Println("could not write comments: ", err)
+		}
+		err = f.Sync()
+		if err != nil {
+			logger.Println("could not flush file: ", err)
+		}
+		err = f.Close()
+		if err != nil {
+			logger.Println("could not close file: ", err)
+		}
+	}
+
+	if copier != nil {
+		for _, att := range renderer.Attachments.Children(it) {
+			if uploadPath, ok := renderer.Site.UploadPath(att.AttachmentURL); ok {
+				renderer.Media.Add(uploadPath)
+			}
+		}
+		err := copier.copyAll(ctx, it, renderer.Media, dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportPages writes the pages of an item split at <!--nextpage--> markers:
+// the first one where the whole item would go, the rest in their own
+// directories next to it
+func exportPages(renderer ContentRenderer, opts Options, it Item, logger *log.Logger) error {
+	docs, err := renderer.ToMarkdownPages(it)
+	if err != nil {
+		logger.Println("could not write post: ", err)
+		return nil
+	}
+	for n, doc := range docs {
+		dir := filepath.Join(opts.OutDir, renderer.Layout.PartDir(it, n+1))
+		file := "index.md"
+		if n == 0 {
+			file = renderer.Layout.IndexFile(it)
+		} else {
+			err := os.MkdirAll(dir, 0750)
+			if err != nil {
+				return fmt.Errorf("could not create dir: %v", err)
+			}
+			logger.Println("created dir", dir)
+		}
+		err := ioutil.WriteFile(filepath.Join(dir, file), doc, 0640)
+		if err != nil {
+			return fmt.Errorf("could not write file: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFetchAttempts is how many times a download is tried
+	DefaultFetchAttempts = 3
+	// DefaultRetryDelay is the wait before the first retry, doubled for each
+	// of the next ones
+	DefaultRetryDelay = time.Second
+	// DefaultMaxMediaSize is the largest media file downloaded, in bytes
+	DefaultMaxMediaSize = 100 << 20
+	// DefaultFetchTimeout is how long a download may take, so that a
+	// stalled server doesn't hang the fetch
+	DefaultFetchTimeout = 2 * time.Minute
+)
+
+// fetchIndexFile lists the downloads in the cache dir, a JSON record per
+// line, so that an interrupted fetch can resume
+const fetchIndexFile = "index.jsonl"
+
+// errTooLarge is returned for downloads over the size limit
+var errTooLarge = fmt.Errorf("file too large: %w", ErrMediaUnavailable)
+
+// Fetcher is a MediaSource that downloads the media of the old site into a
+// content-addressed cache dir: each file is downloaded once, and stored
+// under the SHA-256 of its contents. Files that the site doesn't have are
+// remembered too. It is safe for concurrent use
+type Fetcher struct {
+	Dir      string   // the cache dir
+	BaseURLs []string // URL prefixes to find upload paths under, in order, see Site.UploadBaseURLs
+
+	Client     *http.Client  // one with the Timeout if nil
+	Timeout    time.Duration // per download without a Client, DefaultFetchTimeout if 0
+	Jobs       int           // concurrent downloads, at least 1
+	Attempts   int           // per URL, DefaultFetchAttempts if 0
+	RetryDelay time.Duration // DefaultRetryDelay if 0
+	MaxSize    int64         // DefaultMaxMediaSize if 0
+
+	once     sync.Once
+	initErr  error
+	sem      chan struct{}
+	client   *http.Client
+	mu       sync.Mutex
+	index    map[string]fetchRecord
+	inflight map[string]chan struct{}
+	indexLog *os.File
+}
+
+// fetchRecord is an upload path in the cache index
+type fetchRecord struct {
+	Path    string `json:"path"`
+	URL     string `json:"url,omitempty"`
+	Hash    string `json:"sha256,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// FetchStats counts the outcome of a FetchAll
+type FetchStats struct {
+	Cached, Fetched, Missing, Failed int
+}
+
+// DefaultSchemes are tried in order to download media: sites that never
+// moved to https are found with the second one
+var DefaultSchemes = []string{"https", "http"}
+
+// UploadBaseURLs are where the site served its uploads: the media domains,
+// and /wp-content/uploads/ on its domains, with each of its Schemes
+func (s Site) UploadBaseURLs() []string {
+	schemes := s.Schemes
+	if len(schemes) == 0 {
+		schemes = DefaultSchemes
+	}
+	var bases []string
+	for _, scheme := range schemes {
+		for _, domain := range s.MediaDomains {
+			bases = append(bases, scheme+"://"+domain+"/")
+		}
+		for _, domain := range s.Domains {
+			bases = append(bases, scheme+"://"+domain+wpUploadsPath)
+		}
+	}
+	return bases
+}
+
+// Open opens a media file from the cache, downloading it first if needed
+func (f *Fetcher) Open(ctx context.Context, uploadPath string) (io.ReadCloser, error) {
+	rec, err := f.fetch(ctx, uploadPath)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Missing {
+		return nil, fmt.Errorf("%s: %w", uploadPath, os.ErrNotExist)
+	}
+	return os.Open(f.objectPath(rec.Hash))
+}
+
+// FetchAll downloads the files not in the cache yet, logging the failures
+func (f *Fetcher) FetchAll(ctx context.Context, uploadPaths []string, out io.Writer) (FetchStats, error) {
+	var stats FetchStats
+	err := f.init()
+	if err != nil {
+		return stats, err
+	}
+	var mu sync.Mutex
+	runPool(ctx, f.Jobs, len(uploadPaths), func(i int, logger *log.Logger) error {
+		f.mu.Lock()
+		_, cached := f.index[uploadPaths[i]]
+		f.mu.Unlock()
+		rec, err := f.fetch(ctx, uploadPaths[i])
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			stats.Failed++
+			logger.Println("could not fetch:", err)
+		case cached:
+			stats.Cached++
+		case rec.Missing:
+			stats.Missing++
+			logger.Println("missing:", uploadPaths[i])
+		default:
+			stats.Fetched++
+		}
+		return nil
+	}, out)
+	return stats, ctx.Err()
+}
+
+// Close closes the cache index
+func (f *Fetcher) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.indexLog == nil {
+		return nil
+	}
+	err := f.indexLog.Close()
+	f.indexLog = nil
+	return err
+}
+
+// init reads the cache index, and opens it for appending
+func (f *Fetcher) init() error {
+	f.once.Do(func() {
+		jobs := f.Jobs
+		if jobs < 1 {
+			jobs = 1
+		}
+		f.sem = make(chan struct{}, jobs)
+		f.client = f.Client
+		if f.client == nil {
+			timeout := f.Timeout
+			if timeout <= 0 {
+				timeout = DefaultFetchTimeout
+			}
+			f.client = &http.Client{Timeout: timeout}
+		}
+		f.index = make(map[string]fetchRecord)
+		f.inflight = make(map[string]chan struct{})
+		err := os.MkdirAll(f.Dir, 0750)
+		if err != nil {
+			f.initErr = fmt.Errorf("could not create cache dir: %v", err)
+			return
+		}
+		indexFile := filepath.Join(f.Dir, fetchIndexFile)
+		if r, err := os.Open(indexFile); err == nil {
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				var rec fetchRecord
+				// a line cut short by an interruption is fetched again
+				if json.Unmarshal(scanner.Bytes(), &rec) == nil && len(rec.Path) > 0 {
+					f.index[rec.Path] = rec
+				}
+			}
+			r.Close()
+		}
+		f.indexLog, err = os.OpenFile(indexFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+		if err != nil {
+			f.initErr = fmt.Errorf("could not open cache index: %v", err)
+		}
+	})
+	return f.initErr
+}
+
+// fetch gives the cache record of an upload path, downloading it if it is
+// not in the cache. Concurrent calls for the same path download it once
+func (f *Fetcher) fetch(ctx context.Context, uploadPath string) (fetchRecord, error) {
+	err := f.init()
+	if err != nil {
+		return fetchRecord{}, err
+	}
+	for {
+		f.mu.Lock()
+		if rec, found := f.index[uploadPath]; found {
+			f.mu.Unlock()
+			return rec, nil
+		}
+		wait, busy := f.inflight[uploadPath]
+		if !busy {
+			f.inflight[uploadPath] = make(chan struct{})
+			f.mu.Unlock()
+			break
+		}
+		f.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return fetchRecord{}, ctx.Err()
+		}
+	}
+
+	rec, err := f.download(ctx, uploadPath)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	close(f.inflight[uploadPath])
+	delete(f.inflight, uploadPath)
+	if err != nil {
+		// failures are not cached, the next fetch tries again
+		return rec, err
+	}
+	f.index[uploadPath] = rec
+	line, _ := json.Marshal(rec)
+	if f.indexLog != nil {
+		_, err = f.indexLog.Write(append(line, '\n'))
+		if err != nil {
+			return rec, fmt.Errorf("could not write cache index: %v", err)
+		}
+	}
+	return rec, nil
+}
+
+// download tries the base URLs in order, and stores the first one found.
+// The record is Missing if none has it, and if some failed the error is
+// returned instead, so that the next fetch tries again
+func (f *Fetcher) download(ctx context.Context, uploadPath string) (fetchRecord, error) {
+	select {
+	case f.sem <- struct{}{}:
+		defer func() { <-f.sem }()
+	case <-ctx.Done():
+		return fetchRecord{}, ctx.Err()
+	}
+
+	attempts := f.Attempts
+	if attempts < 1 {
+		attempts = DefaultFetchAttempts
+	}
+	delay := f.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	var failed error
+	for _, base := range f.BaseURLs {
+		url := base + uploadPath
+		var err error
+		for attempt := 1; attempt <= attempts; attempt++ {
+			if attempt > 1 {
+				select {
+				case <-time.After(delay << (attempt - 2)):
+				case <-ctx.Done():
+					return fetchRecord{}, ctx.Err()
+				}
+			}
+			var rec fetchRecord
+			rec, err = f.get(ctx, url)
+			if err == nil {
+				rec.Path = uploadPath
+				return rec, nil
+			}
+			var retry retryableError
+			if !errors.As(err, &retry) {
+				break
+			}
+		}
+		switch {
+		case ctx.Err() != nil:
+			return fetchRecord{}, ctx.Err()
+		case !errors.Is(err, os.ErrNotExist) && failed == nil:
+			failed = err
+		}
+	}
+	if failed != nil {
+		return fetchRecord{}, failed
+	}
+	return fetchRecord{Path: uploadPath, Missing: true}, nil
+}
+
+// retryableError is a download failure that may not happen again
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// get downloads a URL into the cache
+func (f *Fetcher) get(ctx context.Context, url string) (fetchRecord, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fetchRecord{}, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fetchRecord{}, ctx.Err()
+		}
+		return fetchRecord{}, retryableError{fmt.Errorf("%v: %w", err, ErrMediaUnavailable)}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return fetchRecord{}, fmt.Errorf("%s: %w", url, os.ErrNotExist)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fetchRecord{}, retryableError{fmt.Errorf("%s: %s: %w", url, resp.Status, ErrMediaUnavailable)}
+	case resp.StatusCode != http.StatusOK:
+		return fetchRecord{}, fmt.Errorf("%s: %s: %w", url, resp.Status, ErrMediaUnavailable)
+	}
+	maxSize := f.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMediaSize
+	}
+	if resp.ContentLength > maxSize {
+		return fetchRecord{}, fmt.Errorf("%s: %w", url, errTooLarge)
+	}
+
+	tmp, err := os.CreateTemp(f.Dir, "download-")
+	if err != nil {
+		return fetchRecord{}, fmt.Errorf("could not create file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(resp.Body, maxSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fetchRecord{}, retryableError{fmt.Errorf("%s: %v: %w", url, err, ErrMediaUnavailable)}
+	}
+	if size > maxSize {
+		return fetchRecord{}, fmt.Errorf("%s: %w", url, errTooLarge)
+	}
+
+	rec := fetchRecord{URL: url, Hash: hex.EncodeToString(hash.Sum(nil)), Size: size}
+	object := f.objectPath(rec.Hash)
+	err = os.MkdirAll(filepath.Dir(object), 0750)
+	if err != nil {
+		return fetchRecord{}, fmt.Errorf("could not create dir: %v", err)
+	}
+	err = os.Rename(tmp.Name(), object)
+	if err != nil {
+		return fetchRecord{}, fmt.Errorf("could not store %s: %v", url, err)
+	}
+	return rec, nil
+}
+
+// objectPath is where the file with the given hash is in the cache
+func (f *Fetcher) objectPath(hash string) string {
+	return filepath.Join(f.Dir, "objects", hash[:2], hash)
+}
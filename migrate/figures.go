@@ -0,0 +1,187 @@
+package migrate
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// captionRegexp matches the [caption] shortcodes of WordPress
+	captionRegexp = regexp.MustCompile(`(?s)\[caption([^\]]*)\](.*?)\[/caption\]`)
+	// imageBlockRegexp matches an image, and the link around it
+	imageBlockRegexp = regexp.MustCompile(`(?is)(?:<a\s[^>]*>\s*)?<img\s[^>]*>(?:\s*</a>)?`)
+	// linkTagRegexp matches the opening tag of a link
+	linkTagRegexp = regexp.MustCompile(`(?i)<a\s[^>]*>`)
+	// imgTagRegexp matches an img tag
+	imgTagRegexp = regexp.MustCompile(`(?i)<img\s[^>]*>`)
+	// shortcodeAttrRegexp matches the attributes of a WordPress shortcode,
+	// quoted or not
+	shortcodeAttrRegexp = regexp.MustCompile(`([\w-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'\]]+))`)
+	// wpImageRegexp matches the class WordPress gives images from the media
+	// library, with the ID of the attachment
+	wpImageRegexp = regexp.MustCompile(`\bwp-image-(\d+)\b`)
+	// alignRegexp matches the alignment classes of WordPress
+	alignRegexp = regexp.MustCompile(`\balign(left|right|center|none)\b`)
+)
+
+// figure is an image with its caption, as in a Hugo figure shortcode
+type figure struct {
+	Src, Link, Alt, Title, Caption, Class, Width, Height string
+}
+
+// tagAttrs reads the attributes of an HTML tag, with lowercase names
+func tagAttrs(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrRegexp.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = m[3] + m[4]
+	}
+	return attrs
+}
+
+// shortcodeAttrs reads the attributes of a WordPress shortcode
+func shortcodeAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range shortcodeAttrRegexp.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+	}
+	return attrs
+}
+
+// newFigure reads an image, and the link around it, into a figure. The
+// attachment in the wp-image-N class gives the alt text when the image has
+// none, and links to the attachment page are pointed at the image itself
+func newFigure(tc TransformContext, block string) (figure, bool) {
+	img := tagAttrs(imgTagRegexp.FindString(block))
+	if len(img["src"]) == 0 {
+		return figure{}, false
+	}
+	fig := figure{
+		Src:    img["src"],
+		Alt:    img["alt"],
+		Title:  img["title"],
+		Width:  img["width"],
+		Height: img["height"],
+	}
+	if link := linkTagRegexp.FindString(block); len(link) > 0 {
+		fig.Link = tagAttrs(link)["href"]
+	}
+	if m := alignRegexp.FindStringSubmatch(img["class"]); m != nil && m[1] != "none" {
+		fig.Class = "align-" + m[1]
+	}
+	if m := wpImageRegexp.FindStringSubmatch(img["class"]); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		if att, found := tc.Attachments[id]; found {
+			if alt, found := att.Meta("_wp_attachment_image_alt"); found && len(strings.TrimSpace(fig.Alt)) == 0 {
+				fig.Alt = html.EscapeString(strings.TrimSpace(alt))
+			}
+			if isAttachmentPage(tc, fig.Link, att) {
+				fig.Link = fig.Src
+			}
+		}
+	}
+	return fig, true
+}
+
+// isAttachmentPage tells if a link points to the WordPress page of an
+// attachment, rather than to its file
+func isAttachmentPage(tc TransformContext, link string, att Item) bool {
+	if len(link) == 0 {
+		return false
+	}
+	link = html.UnescapeString(link)
+	if strings.Contains(link, fmt.Sprintf("attachment_id=%d", att.ID)) || link == att.Link {
+		return true
+	}
+	p := tc.Site.sitePath(att.Link)
+	return len(p) > 0 && normalizePath(link) == normalizePath(p)
+}
+
+// convertFigures turns the [caption] shortcodes, and the images WordPress
+// inserted from its media library, into figures: Hugo figure shortcodes in
+// the content and <figure> elements in comments, which Hugo doesn't process.
+// Alignment classes become align-left, align-right and align-center
+func convertFigures(tc TransformContext, content string) string {
+	if tc.Site == nil {
+		return content
+	}
+	render := figureShortcode
+	if tc.Comment != nil {
+		render = figureHTML
+	}
+	content = captionRegexp.ReplaceAllStringFunc(content, func(caption string) string {
+		m := captionRegexp.FindStringSubmatch(caption)
+		block := imageBlockRegexp.FindString(m[2])
+		fig, ok := newFigure(tc, block)
+		if !ok {
+			return caption
+		}
+		attrs := shortcodeAttrs(m[1])
+		fig.Caption = strings.TrimSpace(strings.Replace(m[2], block, "", 1))
+		if len(fig.Caption) == 0 {
+			fig.Caption = attrs["caption"]
+		}
+		if m := alignRegexp.FindStringSubmatch(attrs["align"]); m != nil && m[1] != "none" {
+			fig.Class = "align-" + m[1]
+		}
+		return render(fig)
+	})
+	return imageBlockRegexp.ReplaceAllStringFunc(content, func(block string) string {
+		class := tagAttrs(imgTagRegexp.FindString(block))["class"]
+		if !wpImageRegexp.MatchString(class) && !alignRegexp.MatchString(class) {
+			return block // not from the media library, maybe inline
+		}
+		fig, ok := newFigure(tc, block)
+		if !ok {
+			return block
+		}
+		return render(fig)
+	})
+}
+
+// figureShortcode renders a figure as a Hugo shortcode
+func figureShortcode(fig figure) string {
+	var b strings.Builder
+	b.WriteString("{{< figure")
+	for _, param := range []struct{ name, value string }{
+		{"src", fig.Src}, {"link", fig.Link}, {"alt", fig.Alt}, {"title", fig.Title},
+		{"caption", fig.Caption}, {"class", fig.Class}, {"width", fig.Width}, {"height", fig.Height},
+	} {
+		if len(param.value) > 0 {
+			value := strings.Join(strings.Fields(param.value), " ")
+			fmt.Fprintf(&b, ` %s="%s"`, param.name, strings.ReplaceAll(value, `"`, `\"`))
+		}
+	}
+	b.WriteString(" >}}")
+	return b.String()
+}
+
+// figureHTML renders a figure as HTML
+func figureHTML(fig figure) string {
+	var b strings.Builder
+	b.WriteString("<figure")
+	if len(fig.Class) > 0 {
+		fmt.Fprintf(&b, ` class="%s"`, fig.Class)
+	}
+	b.WriteString(">")
+	if len(fig.Link) > 0 {
+		fmt.Fprintf(&b, `<a href="%s">`, fig.Link)
+	}
+	fmt.Fprintf(&b, `<img src="%s" alt="%s"`, fig.Src, fig.Alt)
+	for _, attr := range []struct{ name, value string }{{"title", fig.Title}, {"width", fig.Width}, {"height", fig.Height}} {
+		if len(attr.value) > 0 {
+			fmt.Fprintf(&b, ` %s="%s"`, attr.name, attr.value)
+		}
+	}
+	b.WriteString(">")
+	if len(fig.Link) > 0 {
+		b.WriteString("</a>")
+	}
+	if len(fig.Caption) > 0 {
+		fmt.Fprintf(&b, "<figcaption>%s</figcaption>", fig.Caption)
+	}
+	b.WriteString("</figure>")
+	return b.String()
+}
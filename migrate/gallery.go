@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// galleryRegexp matches the [gallery] shortcodes of WordPress
+var galleryRegexp = regexp.MustCompile(`\[gallery([^\]]*)\]`)
+
+// DefaultGalleryColumns is how many columns WordPress lays galleries out in
+const DefaultGalleryColumns = 3
+
+// renderGalleries replaces the [gallery] shortcodes with a grid of figures.
+// The images are those listed in the ids (or include) attribute, in that
+// order, or else the attachments uploaded to the item, by menu_order. They
+// link to their files unless the gallery says link="none", and their
+// captions are the attachment excerpts. With bundle media the images are
+// copied into the page bundle. Galleries with no images are left alone
+func renderGalleries(tc TransformContext, content string) string {
+	if tc.Comment != nil || tc.Item == nil || tc.Site == nil {
+		return content
+	}
+	return galleryRegexp.ReplaceAllStringFunc(content, func(shortcode string) string {
+		attrs := shortcodeAttrs(galleryRegexp.FindStringSubmatch(shortcode)[1])
+		var images []Item
+		ids := attrs["ids"]
+		if len(ids) == 0 {
+			ids = attrs["include"]
+		}
+		if len(ids) > 0 {
+			for _, field := range strings.Split(ids, ",") {
+				id, err := strconv.Atoi(strings.TrimSpace(field))
+				if att, found := tc.Attachments[id]; err == nil && found && len(att.AttachmentURL) > 0 {
+					images = append(images, att)
+				}
+			}
+		} else {
+			exclude := make(map[string]bool)
+			for _, field := range strings.Split(attrs["exclude"], ",") {
+				exclude[strings.TrimSpace(field)] = true
+			}
+			for _, att := range tc.Attachments.Children(*tc.Item) {
+				if !exclude[strconv.Itoa(att.ID)] {
+					images = append(images, att)
+				}
+			}
+		}
+		if len(images) == 0 {
+			return shortcode
+		}
+
+		columns, err := strconv.Atoi(attrs["columns"])
+		if err != nil || columns < 1 {
+			columns = DefaultGalleryColumns
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "<div class=\"gallery gallery-columns-%d\">\n", columns)
+		for _, att := range images {
+			src := tc.Site.MediaURL(att.AttachmentURL)
+			if uploadPath, ok := tc.Site.UploadPath(att.AttachmentURL); ok && tc.Media != nil {
+				src = tc.Media.Add(uploadPath)
+			}
+			fig := figure{Src: html.EscapeString(src), Class: "gallery-item"}
+			if attrs["link"] != "none" {
+				fig.Link = fig.Src
+			}
+			if alt, found := att.Meta("_wp_attachment_image_alt"); found {
+				fig.Alt = html.EscapeString(strings.TrimSpace(alt))
+			}
+			fig.Caption = strings.TrimSpace(encodedData(att, excerptSpace))
+			b.WriteString(figureHTML(fig))
+			b.WriteString("\n")
+		}
+		b.WriteString("</div>")
+		return b.String()
+	})
+}
@@ -0,0 +1,213 @@
+package migrate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Where media live, as classified by an Inventory
+const (
+	MediaLocal      = "local"       // under /wp-content/uploads/ of the site
+	MediaRemote     = "remote"      // on the site's media domains
+	MediaThirdParty = "third-party" // anywhere else
+)
+
+// Whether the media of an Inventory were found in the uploads dir
+const (
+	MediaFound        = "found"
+	MediaMissing      = "missing"
+	MediaUnreferenced = "unreferenced" // in the uploads dir, but not used
+	MediaUnchecked    = "unchecked"    // third-party, or no uploads dir
+)
+
+// Where an Inventory found the references to media
+const (
+	SourceContent    = "content"
+	SourceAttachment = "attachment"
+	SourceComment    = "comment"
+	SourcePostMeta   = "postmeta"
+)
+
+// InventoryEntry is a media file of a site, and where it is used
+type InventoryEntry struct {
+	Status     string   `json:"status"`
+	Class      string   `json:"class,omitempty"`
+	UploadPath string   `json:"uploadPath,omitempty"` // for local and remote media
+	URL        string   `json:"url,omitempty"`        // as first found
+	Items      []int    `json:"items,omitempty"`      // IDs of the items using it
+	Sources    []string `json:"sources,omitempty"`
+}
+
+// MediaExtensions are the file extensions taken for media in URLs outside of
+// the site
+var MediaExtensions = []string{
+	".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg", ".bmp", ".tif", ".tiff", ".ico",
+	".mp3", ".m4a", ".ogg", ".wav", ".mp4", ".m4v", ".mov", ".webm", ".avi",
+	".pdf", ".zip", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".odt",
+}
+
+// anyURLRegexp matches the URLs in attributes and in free text
+var anyURLRegexp = regexp.MustCompile(`(?:(?:src|href)\s*=\s*["']([^"']+)["'])|(https?://[^\s"'<>\[\]]+)`)
+
+// Inventory lists the media used by the items of an export, in their
+// content, attachment URLs, comments and postmeta, sorted by upload path
+// and URL. Resized images count as their originals. If uploadsDir is not empty the local and remote media are looked
+// up there, and the files there that aren't used are listed too
+func (s Site) Inventory(items []Item, uploadsDir string) ([]InventoryEntry, error) {
+	entries := make(map[string]*InventoryEntry)
+	originals := NewAttachments(items).UploadPaths(s)
+	add := func(ref string, it Item, source string) {
+		if variant, ok := s.ImageVariant(ref, originals); ok {
+			ref = originalURL(ref, variant)
+		}
+		entry, key := s.inventoryEntry(ref)
+		if entry == nil {
+			return
+		}
+		if entries[key] == nil {
+			entries[key] = entry
+		}
+		entry = entries[key]
+		if n := len(entry.Items); n == 0 || entry.Items[n-1] != it.ID {
+			entry.Items = append(entry.Items, it.ID)
+		}
+		if !contains(entry.Sources, source) {
+			entry.Sources = append(entry.Sources, source)
+		}
+	}
+	scan := func(text string, it Item, source string) {
+		for _, m := range anyURLRegexp.FindAllStringSubmatch(text, -1) {
+			// free URLs often end a sentence
+			add(m[1]+strings.TrimRight(m[2], ".,;:!?)"), it, source)
+		}
+	}
+
+	for _, it := range items {
+		for _, enc := range it.Encodeds {
+			scan(enc.Data, it, SourceContent)
+		}
+		if len(it.AttachmentURL) > 0 {
+			add(it.AttachmentURL, it, SourceAttachment)
+		}
+		for _, c := range it.Comments {
+			scan(string(c.Content), it, SourceComment)
+		}
+		for _, meta := range it.PostMeta {
+			scan(meta.MetaValue.Value, it, SourcePostMeta)
+		}
+	}
+
+	for _, entry := range entries {
+		sort.Ints(entry.Items)
+		entry.Status = MediaUnchecked
+	}
+	if len(uploadsDir) > 0 {
+		for _, entry := range entries {
+			if len(entry.UploadPath) == 0 {
+				continue
+			}
+			_, err := os.Stat(filepath.Join(uploadsDir, filepath.FromSlash(entry.UploadPath)))
+			switch {
+			case err == nil:
+				entry.Status = MediaFound
+			case os.IsNotExist(err):
+				entry.Status = MediaMissing
+			default:
+				return nil, fmt.Errorf("could not check %s: %v", entry.UploadPath, err)
+			}
+		}
+		err := filepath.WalkDir(uploadsDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(uploadsDir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if entries[rel] == nil {
+				entries[rel] = &InventoryEntry{Status: MediaUnreferenced, UploadPath: rel}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not list the uploads: %v", err)
+		}
+	}
+
+	list := make([]InventoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].UploadPath != list[j].UploadPath {
+			return list[i].UploadPath < list[j].UploadPath
+		}
+		return list[i].URL < list[j].URL
+	})
+	return list, nil
+}
+
+// inventoryEntry classifies a reference, and gives the key for the file it
+// points to. The entry is nil if the reference is not to media
+func (s Site) inventoryEntry(ref string) (*InventoryEntry, string) {
+	ref = strings.TrimSpace(html.UnescapeString(ref))
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, ""
+	}
+	if uploadPath, ok := s.UploadPath(ref); ok {
+		class := MediaLocal
+		if contains(s.MediaDomains, u.Host) {
+			class = MediaRemote
+		}
+		return &InventoryEntry{Class: class, UploadPath: uploadPath, URL: ref}, uploadPath
+	}
+	if len(u.Host) == 0 || contains(s.Domains, u.Host) ||
+		!contains(MediaExtensions, strings.ToLower(path.Ext(u.Path))) {
+		return nil, ""
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return &InventoryEntry{Class: MediaThirdParty, URL: ref}, u.String()
+}
+
+// WriteInventoryCSV writes an inventory as CSV, with a header
+func WriteInventoryCSV(w io.Writer, entries []InventoryEntry) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"status", "class", "upload_path", "url", "items", "sources"})
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		ids := make([]string, len(entry.Items))
+		for i, id := range entry.Items {
+			ids[i] = strconv.Itoa(id)
+		}
+		err := cw.Write([]string{entry.Status, entry.Class, entry.UploadPath, entry.URL,
+			strings.Join(ids, " "), strings.Join(entry.Sources, " ")})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteInventoryJSON writes an inventory as a JSON array
+func WriteInventoryJSON(w io.Writer, entries []InventoryEntry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
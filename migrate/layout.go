@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Layout places the exported items in the new site: their URL, and the
+// directory and file of their page bundle.
+// Pages follow their post_parent hierarchy: a page with children becomes a
+// branch bundle, with an _index.md file, and its children are nested in it
+type Layout struct {
+	Permalinks Permalinks
+	Statuses   StatusPolicy // items with ActionPrivate go in the private section
+	// Authors name the %author% of the items by their slug, so HideLogins
+	// keeps logins out of the URLs. The login is used if nil
+	Authors Authors
+	// Categories add the ancestors to the %category% of the items, as
+	// WordPress does. Only the nicename is used if nil
+	Categories Terms
+	pages      *pageTree
+}
+
+// NewLayout builds the layout for the items to export. The warnings tell of
+// pages whose parent is missing, or which are their own ancestors: those are
+// placed at the top level. They also tell of items that get the URL or the
+// directory of another one
+func NewLayout(permalinks Permalinks, statuses StatusPolicy, authors Authors, categories Terms,
+	items []Item) (Layout, []string) {
+	pages, warnings := newPageTree(items)
+	l := Layout{Permalinks: permalinks, Statuses: statuses, Authors: authors, Categories: categories, pages: pages}
+	return l, append(warnings, l.clashes(items)...)
+}
+
+// clashes tells of the items with the same directory as an earlier one,
+// which overwrite it, or else the same URL, where Hugo only publishes one
+// of them. Private items have no URL
+func (l Layout) clashes(items []Item) []string {
+	var warnings []string
+	dirs := make(map[string]Item)
+	urls := make(map[string]Item)
+	for _, it := range items {
+		dir := l.Dir(it)
+		if first, found := dirs[dir]; found {
+			warnings = append(warnings, fmt.Sprintf("%s %d %q: same directory %s as %s %d, overwriting it",
+				it.PostType, it.ID, it.Slug, dir, first.PostType, first.ID))
+			continue
+		}
+		dirs[dir] = it
+		if l.Statuses.Action(it.Status) == ActionPrivate {
+			continue
+		}
+		u := l.URL(it)
+		if first, found := urls[u]; found {
+			warnings = append(warnings, fmt.Sprintf("%s %d %q: same URL %s as %s %d",
+				it.PostType, it.ID, it.Slug, u, first.PostType, first.ID))
+			continue
+		}
+		urls[u] = it
+	}
+	return warnings
+}
+
+// Path is the expanded permalink of an item, without leading or trailing
+// slashes
+func (l Layout) Path(i Item) string {
+	return l.Permalinks.path(i, l.pagename(i), l.author(i), l.category(i))
+}
+
+// URL is the site-relative URL of an item, with leading and trailing slashes
+func (l Layout) URL(i Item) string {
+	return "/" + l.Path(i) + "/"
+}
+
+// PartURL is the URL of the nth page of an item split at <!--nextpage-->
+// markers, as in WordPress. The first page has the item's URL
+func (l Layout) PartURL(i Item, n int) string {
+	if n <= 1 {
+		return l.URL(i)
+	}
+	return fmt.Sprintf("%s%d/", l.URL(i), n)
+}
+
+// PartDir is the directory of the nth page of an item split at
+// <!--nextpage--> markers. The first page is in the item's directory, the
+// rest in leaf bundles next to it
+func (l Layout) PartDir(i Item, n int) string {
+	if n <= 1 {
+		return l.Dir(i)
+	}
+	return fmt.Sprintf("%s-%d", l.Dir(i), n)
+}
+
+// Dir is the directory of an item's page bundle, relative to the content
+// dir: its section (the post type) and its permalink path. The default
+// permalink already starts with the post type, which is not repeated
+func (l Layout) Dir(i Item) string {
+	section := i.PostType
+	if l.Statuses.Action(i.Status) == ActionPrivate {
+		section = filepath.Join(PrivateSection, i.PostType)
+	}
+	p := l.Path(i)
+	if !l.Permalinks.has(i.PostType) {
+		p = strings.TrimPrefix(p, i.PostType+"/")
+	}
+	return filepath.Join(section, filepath.FromSlash(p))
+}
+
+// IndexFile is the name of the content file in the item's bundle: _index.md
+// for pages with children, index.md otherwise
+func (l Layout) IndexFile(i Item) string {
+	if l.pages != nil && l.pages.hasChildren(i) {
+		return "_index.md"
+	}
+	return "index.md"
+}
+
+// ContentFile is the path of the item's content file, relative to the
+// content dir
+func (l Layout) ContentFile(i Item) string {
+	return filepath.Join(l.Dir(i), l.IndexFile(i))
+}
+
+// author is the slug of the item's author. Authors left out by HideLogins
+// have none
+func (l Layout) author(i Item) string {
+	if l.Authors == nil {
+		return i.Author
+	}
+	return l.Authors[i.Author].Slug
+}
+
+// category is the path of the item's category with the lowest term ID,
+// which is the one WordPress puts in permalinks
+func (l Layout) category(i Item) string {
+	var first Term
+	found := false
+	for _, ct := range i.Categories {
+		term, known := l.Categories[ct.NiceName]
+		if ct.Domain != "category" || !known {
+			continue
+		}
+		if !found || term.ID > 0 && (first.ID == 0 || term.ID < first.ID) {
+			first, found = term, true
+		}
+	}
+	if !found {
+		return firstCategory(i)
+	}
+	return l.Categories.path(first)
+}
+
+// pagename is the slug of a page preceded by those of its ancestors
+func (l Layout) pagename(i Item) string {
+	if l.pages == nil {
+		return i.Slug
+	}
+	slugs := []string{i.Slug}
+	for _, anc := range l.pages.ancestors(i) {
+		slugs = append([]string{anc.Slug}, slugs...)
+	}
+	return path.Join(slugs...)
+}
+
+// pageTree holds the hierarchy of the exported pages
+type pageTree struct {
+	byID     map[int]Item
+	parent   map[int]int // page ID -> parent page ID, for pages with a parent
+	children map[int]int // page ID -> number of children
+}
+
+func newPageTree(items []Item) (*pageTree, []string) {
+	t := &pageTree{
+		byID:     make(map[int]Item),
+		parent:   make(map[int]int),
+		children: make(map[int]int),
+	}
+	for _, it := range items {
+		if it.PostType == "page" {
+			t.byID[it.ID] = it
+		}
+	}
+
+	var warnings []string
+	ids := make([]int, 0, len(t.byID))
+	for id := range t.byID {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		parentID := t.byID[id].PostParent
+		if parentID == 0 {
+			continue
+		}
+		if _, found := t.byID[parentID]; !found {
+			warnings = append(warnings, fmt.Sprintf(
+				"page %d %q: parent %d is not exported, placing it at the top level",
+				id, t.byID[id].Slug, parentID))
+			continue
+		}
+		t.parent[id] = parentID
+	}
+	// break the cycles at the first page found to be its own ancestor
+	for _, id := range ids {
+		visited := map[int]bool{id: true}
+		chain := []string{t.byID[id].Slug}
+		for p, found := t.parent[id]; found; p, found = t.parent[p] {
+			chain = append(chain, t.byID[p].Slug)
+			if visited[p] {
+				delete(t.parent, p)
+				warnings = append(warnings, fmt.Sprintf(
+					"page %d %q: ancestors form a cycle (%s), placing it at the top level",
+					p, t.byID[p].Slug, strings.Join(chain, " > ")))
+				break
+			}
+			visited[p] = true
+		}
+	}
+	for _, parentID := range t.parent {
+		t.children[parentID]++
+	}
+	return t, warnings
+}
+
+// ancestors lists the parent of a page, its grandparent and so on
+func (t *pageTree) ancestors(i Item) []Item {
+	if i.PostType != "page" {
+		return nil
+	}
+	var ancestors []Item
+	for p, found := t.parent[i.ID]; found; p, found = t.parent[p] {
+		ancestors = append(ancestors, t.byID[p])
+	}
+	return ancestors
+}
+
+func (t *pageTree) hasChildren(i Item) bool {
+	return i.PostType == "page" && t.children[i.ID] > 0
+}
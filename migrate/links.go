@@ -0,0 +1,223 @@
+package migrate
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Ways LinkIndex rewrites the links it resolves
+const (
+	LinksURL    = "url"    // the item's URL in the new site
+	LinksRelref = "relref" // a Hugo relref shortcode to the item's page
+)
+
+// LinkIndex finds the exported item an internal link points to, by the
+// item's permalink and other old URLs, by its ID in ?p=ID style links, or by
+// its slug.
+// Links that point into the site but can't be resolved are recorded, see
+// Unresolved, except those to the pages of attachments, which are not
+// exported. It is safe for concurrent use
+type LinkIndex struct {
+	site   Site
+	mode   string
+	byPath map[string]*linkTarget
+	byID   map[int]*linkTarget
+	bySlug map[string]*linkTarget // nil for slugs shared by several items
+
+	attachments     Attachments
+	attachmentPaths map[string]bool
+
+	mu         sync.Mutex
+	unresolved map[BrokenLink]bool
+}
+
+// linkTarget is where an item ended up
+type linkTarget struct {
+	url         string // site-relative URL, with the slashes
+	contentPath string // of the index.md file, relative to the content dir
+}
+
+// BrokenLink is an internal link that does not resolve to any exported item
+type BrokenLink struct {
+	ItemID int    // the item the link is in
+	Href   string // the link target
+}
+
+// NewLinkIndex indexes the items to be exported. pageURL gives the new URL of
+// an item, and contentFile the path of its index.md file relative to the
+// content dir. Links to the pages of the attachments are not reported when
+// they don't resolve.
+// The mode is LinksURL or LinksRelref
+func NewLinkIndex(
+	site Site, items []Item, attachments Attachments, pageURL, contentFile func(Item) string, mode string,
+) (*LinkIndex, error) {
+	if mode != LinksURL && mode != LinksRelref {
+		return nil, fmt.Errorf("unknown link mode %q, want %s or %s", mode, LinksURL, LinksRelref)
+	}
+	ix := &LinkIndex{
+		site:       site,
+		mode:       mode,
+		byPath:     make(map[string]*linkTarget),
+		byID:       make(map[int]*linkTarget),
+		bySlug:     make(map[string]*linkTarget),
+		unresolved: make(map[BrokenLink]bool),
+
+		attachments:     attachments,
+		attachmentPaths: make(map[string]bool),
+	}
+	for _, att := range attachments {
+		if u, err := url.Parse(att.Link); err == nil && len(u.Path) > 0 {
+			ix.attachmentPaths[normalizePath(u.EscapedPath())] = true
+		}
+	}
+	// the first item with a URL keeps it, and the URLs of the items win over
+	// the aliases of others
+	targets := make([]*linkTarget, len(items))
+	for n, it := range items {
+		itemURL := pageURL(it)
+		targets[n] = &linkTarget{
+			url:         normalizePath(itemURL) + "/",
+			contentPath: filepath.ToSlash(contentFile(it)),
+		}
+		if _, found := ix.byPath[normalizePath(itemURL)]; !found {
+			ix.byPath[normalizePath(itemURL)] = targets[n]
+		}
+	}
+	for n, it := range items {
+		target := targets[n]
+		for _, alias := range site.Aliases(it, pageURL(it)) {
+			if _, found := ix.byPath[normalizePath(alias)]; !found {
+				ix.byPath[normalizePath(alias)] = target
+			}
+		}
+		ix.byID[it.ID] = target
+		if _, found := ix.bySlug[it.Slug]; found {
+			ix.bySlug[it.Slug] = nil
+		} else {
+			ix.bySlug[it.Slug] = target
+		}
+	}
+	return ix, nil
+}
+
+// nonItemPaths start the paths of the WordPress pages that are not items:
+// archives, feeds, media ...
+var nonItemPaths = []string{
+	"/category/", "/tag/", "/author/", "/page/", "/feed/", "/comments/",
+	"/wp-content/", "/wp-admin/", "/wp-includes/", "/files/",
+}
+
+// Resolve finds the new target for a link. It returns false for links that
+// don't point to an item in the site, which should be left alone. Internal
+// links that can't be resolved are recorded against the item with itemID
+func (ix *LinkIndex) Resolve(href string, itemID int, forHugo bool) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || (len(u.Host) > 0 && !contains(ix.site.Domains, u.Host)) {
+		return "", false
+	}
+	if len(u.Host) == 0 && (len(u.Scheme) > 0 || !strings.HasPrefix(u.Path, "/")) {
+		return "", false // mailto:, anchors and relative links
+	}
+	p := u.EscapedPath()
+	for _, prefix := range nonItemPaths {
+		if strings.HasPrefix(p, prefix) {
+			return "", false
+		}
+	}
+
+	target := ix.lookup(u)
+	if target == nil {
+		if normalizePath(p) == "/" && len(u.RawQuery) == 0 {
+			return "", false // the home page
+		}
+		if ix.isAttachment(u) {
+			return "", false
+		}
+		ix.mu.Lock()
+		ix.unresolved[BrokenLink{ItemID: itemID, Href: href}] = true
+		ix.mu.Unlock()
+		return "", false
+	}
+
+	resolved := target.url
+	if forHugo && ix.mode == LinksRelref {
+		resolved = fmt.Sprintf(`{{< relref "/%s" >}}`, target.contentPath)
+	}
+	if len(u.Fragment) > 0 {
+		resolved += "#" + u.Fragment
+	}
+	return resolved, true
+}
+
+func (ix *LinkIndex) lookup(u *url.URL) *linkTarget {
+	query := u.Query()
+	for _, key := range []string{"p", "page_id", "attachment_id"} {
+		if v := query.Get(key); len(v) > 0 {
+			id, err := strconv.Atoi(v)
+			if err == nil {
+				return ix.byID[id]
+			}
+		}
+	}
+	if target, found := ix.byPath[normalizePath(u.EscapedPath())]; found {
+		return target
+	}
+	slug := path.Base(normalizePath(u.EscapedPath()))
+	return ix.bySlug[slug]
+}
+
+// isAttachment tells if a link is to the page of an attachment, by its
+// attachment_id or its permalink
+func (ix *LinkIndex) isAttachment(u *url.URL) bool {
+	if id, err := strconv.Atoi(u.Query().Get("attachment_id")); err == nil {
+		if _, found := ix.attachments[id]; found {
+			return true
+		}
+	}
+	return ix.attachmentPaths[normalizePath(u.EscapedPath())]
+}
+
+// Unresolved lists the internal links that did not resolve, sorted by item
+// and link
+func (ix *LinkIndex) Unresolved() []BrokenLink {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	broken := make([]BrokenLink, 0, len(ix.unresolved))
+	for bl := range ix.unresolved {
+		broken = append(broken, bl)
+	}
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].ItemID != broken[j].ItemID {
+			return broken[i].ItemID < broken[j].ItemID
+		}
+		return broken[i].Href < broken[j].Href
+	})
+	return broken
+}
+
+// hrefRegexp matches the href attribute of links, with either quote
+var hrefRegexp = regexp.MustCompile(`(<a\s[^>]*?href\s*=\s*)("([^"]*)"|'([^']*)')`)
+
+// resolveLinks points links to other items in the site at their new pages.
+// Comments are not processed by Hugo, so they always get plain URLs
+func resolveLinks(tc TransformContext, content string) string {
+	if tc.Links == nil || tc.Item == nil {
+		return content
+	}
+	return hrefRegexp.ReplaceAllStringFunc(content, func(attr string) string {
+		m := hrefRegexp.FindStringSubmatch(attr)
+		href := m[3] + m[4]
+		resolved, ok := tc.Links.Resolve(href, tc.Item.ID, tc.Comment == nil)
+		if !ok {
+			return attr
+		}
+		return m[1] + `"` + resolved + `"`
+	})
+}
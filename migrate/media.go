@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// wpUploadsPath is where a self-hosted WordPress serves its media
+const wpUploadsPath = "/wp-content/uploads/"
+
+// Attachments are the attachment items of an export by ID. They are looked
+// up for the media of the other items, whether or not they are exported
+type Attachments map[int]Item
+
+// NewAttachments indexes the attachments among the items
+func NewAttachments(items []Item) Attachments {
+	attachments := make(Attachments)
+	for _, it := range items {
+		if it.PostType == "attachment" {
+			attachments[it.ID] = it
+		}
+	}
+	return attachments
+}
+
+// Featured is the featured image of an item, from its _thumbnail_id
+// postmeta. The second value is false if the item has none, or the
+// attachment isn't in the export
+func (a Attachments) Featured(i Item) (Item, bool) {
+	value, found := i.Meta("_thumbnail_id")
+	if !found {
+		return Item{}, false
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return Item{}, false
+	}
+	att, found := a[id]
+	return att, found && len(att.AttachmentURL) > 0
+}
+
+// Children are the attachments uploaded to an item, by post_parent, sorted
+// by menu_order and ID
+func (a Attachments) Children(i Item) []Item {
+	var children []Item
+	for _, att := range a {
+		if att.PostParent == i.ID && i.ID != 0 && len(att.AttachmentURL) > 0 {
+			children = append(children, att)
+		}
+	}
+	sort.Slice(children, func(m, n int) bool {
+		if children[m].MenuOrder != children[n].MenuOrder {
+			return children[m].MenuOrder < children[n].MenuOrder
+		}
+		return children[m].ID < children[n].ID
+	})
+	return children
+}
+
+// MediaURL is the URL in the new site of media from the old one
+func (s Site) MediaURL(url string) string {
+	url = s.linkReplacer().Replace(url)
+	if strings.HasPrefix(url, wpUploadsPath) {
+		url = strings.TrimSuffix(s.MediaPath, "/") + "/" + strings.TrimPrefix(url, wpUploadsPath)
+	}
+	return url
+}
+
+// UploadPath is where a media URL of the old site is under its uploads
+// directory, e.g. 2009/06/photo.jpg. It recognizes the URLs on the media
+// domains and under /wp-content/uploads/ of the site, and those already
+// rewritten to the media path. The second value is false for other URLs
+func (s Site) UploadPath(ref string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(html.UnescapeString(ref)))
+	if err != nil || len(u.Path) == 0 {
+		return "", false
+	}
+	var p string
+	switch {
+	case contains(s.MediaDomains, u.Host):
+		p = strings.TrimPrefix(u.Path, wpUploadsPath)
+	case len(u.Host) == 0 && len(u.Scheme) == 0 && len(s.MediaPath) > 0 &&
+		strings.HasPrefix(u.Path, strings.TrimSuffix(s.MediaPath, "/")+"/"):
+		p = strings.TrimPrefix(u.Path, strings.TrimSuffix(s.MediaPath, "/"))
+	case (len(u.Host) == 0 && len(u.Scheme) == 0) || contains(s.Domains, u.Host):
+		if !strings.HasPrefix(u.Path, wpUploadsPath) {
+			return "", false
+		}
+		p = strings.TrimPrefix(u.Path, wpUploadsPath)
+	default:
+		return "", false
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if len(p) == 0 || len(path.Ext(p)) == 0 {
+		return "", false
+	}
+	return p, true
+}
+
+// MediaSource gives the media files of a site, by their UploadPath.
+// Files it doesn't have are reported with an error satisfying
+// errors.Is(err, os.ErrNotExist), and files it can't give for now with one
+// satisfying errors.Is(err, ErrMediaUnavailable)
+type MediaSource interface {
+	Open(ctx context.Context, uploadPath string) (io.ReadCloser, error)
+}
+
+// ErrMediaUnavailable is a media file that could not be got, like a failed
+// or too large download. The export reports it and goes on
+var ErrMediaUnavailable = errors.New("media unavailable")
+
+// UploadsDir is a local copy of the wp-content/uploads directory of a site
+type UploadsDir string
+
+// Open opens a file in the uploads dir
+func (d UploadsDir) Open(_ context.Context, uploadPath string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(uploadPath)))
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MetaMapping says which postmeta go into the front matter, and as which
+// fields
+type MetaMapping struct {
+	// Presets are the MetaPresets to use
+	Presets []string `yaml:"presets"`
+	// Fields map meta keys to front matter fields, over the presets. A field
+	// of "-" leaves the key out
+	Fields map[string]string `yaml:"fields"`
+	// Custom adds the custom fields that are not mapped, under their own key
+	Custom bool `yaml:"custom"`
+	// Internal adds, with Custom, the keys starting with an underscore,
+	// which WordPress and its plugins use for their own data
+	Internal bool `yaml:"internal"`
+}
+
+// MetaPresets map the postmeta of popular plugins to front matter fields
+var MetaPresets = map[string]map[string]string{
+	"yoast": {
+		"_yoast_wpseo_metadesc":  "description",
+		"_yoast_wpseo_title":     "seoTitle",
+		"_yoast_wpseo_focuskw":   "focusKeyword",
+		"_yoast_wpseo_canonical": "canonicalURL",
+	},
+	"aioseo": {
+		"_aioseop_description": "description",
+		"_aioseop_title":       "seoTitle",
+		"_aioseop_keywords":    "keywords",
+		"_aioseo_description":  "description",
+		"_aioseo_title":        "seoTitle",
+		"_aioseo_keywords":     "keywords",
+	},
+}
+
+// MetaPresetNames lists the MetaPresets, sorted
+func MetaPresetNames() []string {
+	names := make([]string, 0, len(MetaPresets))
+	for name := range MetaPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the presets exist
+func (m MetaMapping) Validate() error {
+	for _, preset := range m.Presets {
+		if MetaPresets[preset] == nil {
+			return fmt.Errorf("unknown preset %q, want some of %s", preset, strings.Join(MetaPresetNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// frontMatterFields are written by ContentRenderer, so meta can't use them
+var frontMatterFields = []string{
+	"title", "date", "author", TaxonomyAuthors, "original", "slug", "url", "aliases",
+	"featured_image", "images", TaxonomyCategories, TaxonomyTags, "weight", "draft",
+	"publishDate", "lastmod", "part", "parts", "build",
+}
+
+// FrontMatter gives the front matter fields for the postmeta of an item.
+// PHP serialized values are decoded, empty ones left out, and the first of
+// several values for a key wins. Fields in reserved are not set
+func (m MetaMapping) FrontMatter(i Item, reserved ...string) map[string]interface{} {
+	mapping := make(map[string]string)
+	for _, preset := range m.Presets {
+		for key, field := range MetaPresets[preset] {
+			mapping[key] = field
+		}
+	}
+	for key, field := range m.Fields {
+		mapping[key] = field
+	}
+
+	fields := make(map[string]interface{})
+	for _, meta := range i.PostMeta {
+		field, found := mapping[meta.MetaKey]
+		if !found {
+			if !m.Custom || (strings.HasPrefix(meta.MetaKey, "_") && !m.Internal) {
+				continue
+			}
+			field = meta.MetaKey
+		}
+		if field == "-" || len(field) == 0 || contains(frontMatterFields, field) || contains(reserved, field) {
+			continue
+		}
+		if _, set := fields[field]; set || len(strings.TrimSpace(meta.MetaValue.Value)) == 0 {
+			continue
+		}
+		fields[field] = DecodeMeta(meta.MetaValue.Value)
+	}
+	return fields
+}
+
+// metaLines writes front matter fields as YAML, sorted by name
+func metaLines(fields map[string]interface{}) (string, error) {
+	if len(fields) == 0 {
+		return "", nil
+	}
+	out, err := yaml.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("could not write postmeta: %v", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
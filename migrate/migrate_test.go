@@ -0,0 +1,1758 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testXML string
+)
+
+func TestMain(m *testing.M) {
+	fileReader, err := os.OpenFile("testdata/testWpExport.xml", os.O_RDONLY, os.ModeCharDevice)
+	if err != nil {
+		log.Fatalf("could not read test payload: %v", err)
+	}
+	bts, err := ioutil.ReadAll(fileReader)
+	if err != nil {
+		log.Fatalf("could not read xml: %v", err)
+	}
+	testXML = string(bts)
+	err = fileReader.Close()
+	if err != nil {
+		log.Fatalf("could not read xml: %v", err)
+	}
+	os.Exit(m.Run())
+}
+
+func Test_parseXML(t *testing.T) {
+
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		log.Fatalf("could not parse xml: %v", err)
+	}
+
+	if len(doc.Items) != 4 {
+		t.Errorf("expected to find 4 items, found %d", len(doc.Items))
+		t.FailNow()
+	}
+
+	if !strings.Contains(doc.Items[0].Encodeds[0].Data, "http://plazamoyua.files.wordpress.com/2007/01/moyua6.jpg") {
+		t.Errorf("missing content for item1: %s", doc.Items[0].Encodeds[0].Data)
+	}
+	if doc.Items[0].Author != "soil" {
+		t.Errorf("unexpected author for item1: %s", doc.Items[0].Author)
+	}
+	if doc.Items[0].Title != "moyua6.jpg" {
+		t.Errorf("unexpected title for item1: %s", doc.Items[0].Title)
+	}
+	if doc.Items[0].PubDate != "Sat, 20 Jan 2007 06:36:31 +0000" {
+		t.Errorf("unexpected PubDate for item1: %s", doc.Items[0].PubDate)
+	}
+	if doc.Items[0].PostType != "attachment" {
+		t.Errorf("unexpected PubDate for item1: %s", doc.Items[0].PubDate)
+	}
+}
+
+func Test_cleanContent(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		log.Fatalf("could not parse xml: %v", err)
+	}
+
+	if len(doc.Items) != 4 {
+		t.Errorf("expected to find 4 items, found %d", len(doc.Items))
+		t.FailNow()
+	}
+
+	renderer := ContentRenderer{
+		Pipeline: DefaultPipeline(),
+		Site:     DefaultSite(),
+	}
+
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(doc.Items[0], &buff)
+	if err != nil {
+		t.Errorf("could not convert post to markdown: %v", err)
+	}
+	md := buff.String()
+	if strings.Contains(md, "plazamoyua.files.wordpress.com") {
+		t.Errorf("should have eliminated media url reference")
+	}
+	if strings.Contains(md, "http://plazamoyua.files.wordpress.com/2007/01/moyua6.jpg") ||
+		!strings.Contains(md, "/media/2007/01/moyua6.jpg") {
+		t.Errorf("should have eliminated media url reference")
+	}
+
+	if strings.Contains(md, "https://plazamoyua.com/category/fertilizacion-co2/") ||
+		!strings.Contains(md, "/categories/fertilizacion-co2/") {
+		t.Errorf("should have converted category reference")
+	}
+
+	if strings.Contains(md, "https://plazamoyua.com/tag/1010/") ||
+		!strings.Contains(md, "/tags/1010/") {
+		t.Errorf("should have converted tag reference")
+	}
+
+	t.Log(md)
+}
+
+func Test_generateMD(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		log.Fatalf("could not parse xml: %v", err)
+	}
+
+	if len(doc.Items) != 4 {
+		t.Errorf("expected to find 4 items, found %d", len(doc.Items))
+		t.FailNow()
+	}
+
+	renderer := ContentRenderer{Site: DefaultSite()}
+
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(doc.Items[2], &buff)
+	if err != nil {
+		t.Errorf("could not convert post to markdown: %v", err)
+	}
+
+	md := buff.String()
+	expectedWords := []string{"---", "date:", "title:", "author:", "slug:", doc.Items[2].Encodeds[0].Data}
+	for _, word := range expectedWords {
+		t.Run("testing-for-"+word, func(t *testing.T) {
+			if !strings.Contains(md, word) {
+				t.Errorf("missing %s from markdown", word)
+			}
+		})
+	}
+}
+
+func Test_generateHTMLinMD(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		log.Fatalf("could not parse xml: %v", err)
+	}
+
+	if len(doc.Items) != 4 {
+		t.Errorf("expected to find 4 items, found %d", len(doc.Items))
+		t.FailNow()
+	}
+
+	renderer := ContentRenderer{Site: DefaultSite()}
+
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(doc.Items[3], &buff)
+	if err != nil {
+		t.Errorf("could not convert post to markdown: %v", err)
+	}
+
+	md := buff.String()
+
+	extracts := []string{
+		`title: "Las plataformas de hielo de la Antártida, estables. Lástima por los \"fans\" de Wilkins."`,
+		`author: "plazaeme"`,
+		`slug: "las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins"`,
+		`url: "/2009/06/16/las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins/"`,
+		`categories: ["algoreros", "calentamiento-global", "cambio-climatico"]`,
+		`Científicos de la <em><strong>Western Australia's Curtin University of Technology</strong></em> están usando sensores acústicos,`,
+	}
+
+	for _, extract := range extracts {
+		if !strings.Contains(md, extract) {
+			t.Errorf("missing content: %s", extract)
+		}
+	}
+
+	t.Log(md)
+}
+
+func Test_threadComments(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		log.Fatalf("could not parse xml: %v", err)
+	}
+
+	if len(doc.Items) != 4 {
+		t.Errorf("expected to find 4 items, found %d", len(doc.Items))
+		t.FailNow()
+	}
+
+	comments := doc.Items[2].Comments
+	if 3 != len(comments) {
+		t.Errorf("unexpected number of comments: %d. Wanted 3", len(comments))
+	}
+
+	commentThreads := ThreadComments(comments)
+	if 1 != len(commentThreads) {
+		t.Errorf("expected 1 thread, got %d", len(commentThreads))
+		t.FailNow()
+	}
+
+	if commentThreads[0].ID != commentThreads[0].Children[0].ParentID ||
+		commentThreads[0].Children[0].ID != commentThreads[0].Children[0].Children[0].ParentID {
+		t.Errorf("unexpected thread %v", commentThreads[0])
+	}
+}
+
+func Test_renderComments(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Items) != 4 {
+		t.Fatal("expected to find 4 items")
+	}
+
+	comments := doc.Items[2].Comments
+	if 3 != len(comments) {
+		t.Fatal("expected 3 comments")
+	}
+
+	commentThreads := ThreadComments(comments)
+
+	renderer := ContentRenderer{Site: DefaultSite()}
+
+	html, err := renderer.ThreadToHTML(doc.Items[2], commentThreads[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedFragments := []string{
+		`<div class="comment">`,
+		`<span class="author">MOY</span>`,
+		`paco <i>pecho</i> chico rico`,
+		`insultaba como loco`,
+		`<span class="author">Foo</span>`,
+	}
+
+	for _, frag := range expectedFragments {
+		if !strings.Contains(string(html), frag) {
+			t.Errorf("expected to find string %s", frag)
+		}
+	}
+}
+
+func Test_parseCategoriesTags(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		log.Fatalf("could not parse xml: %v", err)
+	}
+
+	if len(doc.Items) != 4 {
+		t.Errorf("expected to find 4 items, found %d", len(doc.Items))
+		t.FailNow()
+	}
+
+	if len(doc.Items[3].Categories) != 4 {
+		t.Errorf("expected 4 categories, found %d", len(doc.Items[3].Categories))
+	}
+
+	var tags, cats int
+	for _, ct := range doc.Items[3].Categories {
+		switch ct.Domain {
+		case "category":
+			cats++
+		case "post_tag":
+			tags++
+		default:
+			t.Errorf("unknown domain: %s", ct.Domain)
+		}
+	}
+	if tags != 1 {
+		t.Errorf("expected 1 tag, found %d", tags)
+	}
+	if cats != 3 {
+		t.Errorf("expected 3 categories, found %d", tags)
+	}
+	expected := Category{
+		XMLName:  xml.Name{Space: "", Local: "category"},
+		Domain:   "post_tag",
+		NiceName: "cambio-climatico",
+		Data:     "Cambio Climático",
+	}
+	if expected != doc.Items[3].Categories[3] {
+		t.Errorf("unexpected tag: %#v", doc.Items[3].Categories[3])
+	}
+}
+
+func TestLinkify(t *testing.T) {
+	in := `Y luego pasó a ser esta dirección. Hace poco funcionaba:
+	http://reode.blogspot.com/ y http://spreadsheets.google.com/pub?key=tl4jqSPuZ3CE5wxyDVwjvMA&single=true&gid=0&output=html también,
+	Pero ya no <a href="http://amazon.com">amazon</a>.
+	Creo que era:
+
+http://ciudadanos-nada-mas.blogspot.com/
+Ahora se pueden ver los resultados provisionales con gran comodidad en una hoja de cálculo que ha preparado "Producciones Críticas":
+
+http://spreadsheets.google.com/pub?key=tl4jqSPuZ3CE5wxyDVwjvMA&amp;single=true&amp;gid=0&amp;output=html`
+
+	out, err := LinkifyText(in)
+	if err != nil {
+		t.Fatalf("could not linkify: %v", err)
+	}
+	frags := []string{
+		`<a href="http://reode.blogspot.com/">http://reode.blogspot.com/</a> y `,
+		`Pero ya no <a href="http://amazon.com">amazon</a>.`,
+		`<a href="http://ciudadanos-nada-mas.blogspot.com/">http://ciudadanos-nada-mas.blogspot.com/</a>`,
+		`<a href="http://spreadsheets.google.com/pub?key=tl4jqSPuZ3CE5wxyDVwjvMA&amp;single=true&amp;gid=0&amp;output=html">http://spreadsheets.google.com/pub?key=tl4jqSPuZ3CE5wxyDVwjvMA&amp;single=true&amp;gid=0&amp;output=html</a>`,
+	}
+	for _, frag := range frags {
+		if !strings.Contains(out, frag) {
+			t.Errorf("expected to find string %s", frag)
+		}
+	}
+	t.Log(string(out))
+}
+
+func Test_statusFrontMatter(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renderer := ContentRenderer{
+		Site:     DefaultSite(),
+		Statuses: DefaultStatusPolicy(),
+	}
+
+	cases := map[string]string{
+		"draft":   "draft: true",
+		"pending": "draft: true",
+		"future":  `publishDate: "2009-06-16T17:57:27Z"`,
+	}
+	for status, expected := range cases {
+		it := doc.Items[3]
+		it.Status = status
+		var buff bytes.Buffer
+		err = renderer.ToMarkdown(it, &buff)
+		if err != nil {
+			t.Fatalf("could not convert post to markdown: %v", err)
+		}
+		if !strings.Contains(buff.String(), expected) {
+			t.Errorf("status %s: expected to find %s in %s", status, expected, buff.String())
+		}
+	}
+
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(doc.Items[3], &buff)
+	if err != nil {
+		t.Fatalf("could not convert post to markdown: %v", err)
+	}
+	if strings.Contains(buff.String(), "draft:") || strings.Contains(buff.String(), "publishDate:") {
+		t.Errorf("published item should not be draft or scheduled: %s", buff.String())
+	}
+
+	renderer.Statuses = StatusPolicy{"private": ActionPrivate}
+	it := doc.Items[3]
+	it.Status = "private"
+	buff.Reset()
+	err = renderer.ToMarkdown(it, &buff)
+	if err != nil {
+		t.Fatalf("could not convert post to markdown: %v", err)
+	}
+	if strings.Contains(buff.String(), "url:") || strings.Contains(buff.String(), "aliases:") ||
+		!strings.Contains(buff.String(), "build:\n  render: never\n  list: never\n  publishResources: false\n") {
+		t.Errorf("private item should not be published: %s", buff.String())
+	}
+}
+
+func Test_statusPolicy(t *testing.T) {
+	policy := DefaultStatusPolicy()
+	if policy.Action("private") != ActionSkip {
+		t.Errorf("private items should be skipped by default")
+	}
+	if policy.Action("some-plugin-status") != ActionSkip {
+		t.Errorf("unknown statuses should be skipped")
+	}
+	if err := policy.Set("private", "private"); err != nil {
+		t.Fatal(err)
+	}
+	if policy.Action("private") != ActionPrivate {
+		t.Errorf("expected private action, got %s", policy.Action("private"))
+	}
+	if err := policy.Set("draft", "publsh"); err == nil {
+		t.Errorf("expected error on bad action name")
+	}
+}
+
+func Test_itemFilter(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := doc.Items[3]
+
+	from := time.Date(2009, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2009, 6, 17, 0, 0, 0, 0, time.UTC)
+	set := func(values ...string) map[string]bool {
+		s := make(map[string]bool)
+		for _, v := range values {
+			s[v] = true
+		}
+		return s
+	}
+	ids := map[int]bool{16: true, 4516: true}
+
+	cases := []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"skipped type", Filter{SkipTypes: set("post")}, false},
+		{"wanted type", Filter{Types: set("page", "post")}, true},
+		{"unwanted type", Filter{Types: set("page")}, false},
+		{"in date window", Filter{From: from, To: to}, true},
+		{"before window", Filter{To: from}, false},
+		{"after window", Filter{From: to}, false},
+		{"category", Filter{Categories: set("foo", "algoreros")}, true},
+		{"missing category", Filter{Categories: set("foo")}, false},
+		{"tag", Filter{Tags: set("cambio-climatico")}, true},
+		{"category is not tag", Filter{Tags: set("algoreros")}, false},
+		{"author", Filter{Authors: set("plazaeme")}, true},
+		{"other author", Filter{Authors: set("soil")}, false},
+		{"id", Filter{IDs: ids}, true},
+		{"all together", Filter{Types: set("post"), Authors: set("soil"), IDs: ids}, false},
+	}
+	for _, c := range cases {
+		if c.filter.Match(post) != c.expected {
+			t.Errorf("%s: expected match to be %v", c.name, c.expected)
+		}
+	}
+}
+
+func Test_runPool(t *testing.T) {
+	var out bytes.Buffer
+	errs := runPool(context.Background(), 4, 50, func(i int, logger *log.Logger) error {
+		logger.Println("task", i)
+		if i%10 == 3 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	}, &out)
+
+	var expected strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintln(&expected, "task", i)
+	}
+	if out.String() != expected.String() {
+		t.Errorf("logs out of order: %s", out.String())
+	}
+
+	if len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got %d", len(errs))
+	}
+	for n, err := range errs {
+		if err.Error() != fmt.Sprintf("failed %d", n*10+3) {
+			t.Errorf("unexpected error #%d: %v", n, err)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	outdir := t.TempDir()
+	var logs bytes.Buffer
+	err := Export(context.Background(), Options{
+		XMLFile: "testdata/testWpExport.xml",
+		OutDir:  outdir,
+		Filter:  Filter{SkipTypes: map[string]bool{"attachment": true}},
+		Jobs:    2,
+		Log:     &logs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := ioutil.ReadFile(filepath.Join(outdir, "post", "2009", "06", "16",
+		"las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins", "index.md"))
+	if err != nil {
+		t.Fatalf("post was not written: %v", err)
+	}
+	if !strings.Contains(string(md), "/media/2009/06/dipuccio-2.jpg") {
+		t.Errorf("content was not cleaned: %s", md)
+	}
+	if !strings.Contains(logs.String(), "read items: 4") || !strings.Contains(logs.String(), "wrote 3 categories") {
+		t.Errorf("unexpected log: %s", logs.String())
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "tags", "cambio-climatico", "_index.md")); err != nil {
+		t.Errorf("term page was not written: %v", err)
+	}
+
+	outdir = t.TempDir()
+	err = Export(context.Background(), Options{
+		XMLFile:   "testdata/testWpExport.xml",
+		OutDir:    outdir,
+		Statuses:  StatusPolicy{"publish": ActionPrivate},
+		Redirects: []string{RedirectsNetlify},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outdir, PrivateSection, "post")); err != nil {
+		t.Errorf("private post was not written: %v", err)
+	}
+	redirects, err := ioutil.ReadFile(filepath.Join(outdir, RedirectsFileName(RedirectsNetlify)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(redirects), "4516") || strings.Contains(string(redirects), "/category/") {
+		t.Errorf("private items should not be redirected to: %s", redirects)
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "categories")); err == nil {
+		t.Errorf("the terms of private items should not get pages")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Export(ctx, Options{XMLFile: "testdata/testWpExport.xml", OutDir: t.TempDir()})
+	if err != context.Canceled {
+		t.Errorf("expected cancelled export, got %v", err)
+	}
+}
+
+func TestExportDrafts(t *testing.T) {
+	outdir := t.TempDir()
+	err := Export(context.Background(), Options{
+		XMLFile:   "testdata/testDraftsExport.xml",
+		OutDir:    outdir,
+		Redirects: []string{RedirectsNetlify},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"2010/03/02/borrador-sobre-el-artico", "2010/03/02/borrador-sobre-el-artico-5004", "2010/03/03/5002"} {
+		md, err := ioutil.ReadFile(filepath.Join(outdir, "post", filepath.FromSlash(dir), "index.md"))
+		if err != nil {
+			t.Errorf("draft without post_name was not written: %v", err)
+			continue
+		}
+		if !strings.Contains(string(md), "draft: true") {
+			t.Errorf("expected a draft: %s", md)
+		}
+	}
+	redirects, err := ioutil.ReadFile(filepath.Join(outdir, RedirectsFileName(RedirectsNetlify)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(redirects), "500") {
+		t.Errorf("drafts should not be redirected to: %s", redirects)
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(outdir, "post", "2010", "03"))
+	if err != nil || len(entries) != 2 {
+		t.Errorf("expected the trashed post to be skipped, got %v %v", entries, err)
+	}
+	md, err := ioutil.ReadFile(filepath.Join(outdir, "post", "2010", "03", "02", "borrador-sobre-el-artico", "index.md"))
+	if err != nil || !strings.Contains(string(md), "Sin terminar.") {
+		t.Errorf("expected drafts with the same title to be kept apart: %s %v", md, err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := DefaultSite()
+	post := doc.Items[3]
+	tc := TransformContext{Item: &post, Site: &site}
+
+	RegisterTransformer("shout", func(tc TransformContext, content string) string {
+		return strings.ToUpper(content)
+	})
+	pipeline, err := NewPipeline("emoticons", "shout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := pipeline.Apply(tc, "hola :lol: http://plazamoyua.com/tag/1010/"); out != "HOLA 😆 HTTP://PLAZAMOYUA.COM/TAG/1010/" {
+		t.Errorf("unexpected transformation: %s", out)
+	}
+	custom := Site{Emoticons: map[string]string{":lol:": "LOL"}}
+	if out := replaceEmoticons(TransformContext{Site: &custom}, ":lol: :)"); out != "LOL :)" {
+		t.Errorf("unexpected custom emoticons: %s", out)
+	}
+	if emoticonReplacer(custom.Emoticons) != emoticonReplacer(custom.Emoticons) {
+		t.Errorf("expected the emoticon replacer to be built once")
+	}
+	if _, err := NewPipeline("emoticons", "whisper"); err == nil {
+		t.Errorf("expected error on unknown transformer")
+	}
+
+	pipeline = DefaultPipeline().Without("emoticons")
+	if strings.Join(pipeline.Names(), ",") != "more,embeds,linkify,rewrites,original-images,bundle-media,resolve-links,self-links,gallery,figures" {
+		t.Errorf("unexpected pipeline: %v", pipeline.Names())
+	}
+	in := "ver https://plazamoyua.com/category/co2/ :lol:"
+	if out := pipeline.Apply(tc, in); out != "ver /categories/co2/ :lol:" {
+		t.Errorf("unexpected transformation of post: %s", out)
+	}
+	tc.Comment = &Comment{}
+	if out := pipeline.Apply(tc, in); out != `ver <a href="/categories/co2/">/categories/co2/</a> :lol:` {
+		t.Errorf("unexpected transformation of comment: %s", out)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	err := LoadConfig("../config.example.yaml", &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := cfg.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.XMLFile != "myWPexport.xml" || opts.OutDir != "exported" || opts.Jobs != 4 {
+		t.Errorf("unexpected options: %#v", opts)
+	}
+	if len(opts.Site.Rewrites) != 1 || opts.Site.Emoticons[":lol:"] != "😆" {
+		t.Errorf("unexpected site: %#v", opts.Site)
+	}
+	if !opts.Filter.Types["page"] || opts.Filter.To != time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("unexpected filter: %#v", opts.Filter)
+	}
+	cfg.MediaCache = "media-cache"
+	if fetcher := cfg.Fetcher(); fetcher.Timeout != 2*time.Minute || fetcher.MaxSize != 100<<20 {
+		t.Errorf("unexpected fetcher: %#v", fetcher)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Input = "foo.xml"
+	cfg.Statuses = map[string]string{"draft": "publsh"}
+	cfg.Transforms = []string{"emoticons", "shrink"}
+	cfg.Filters.From = "2009/06/01"
+	cfg.FetchTimeout = -time.Second
+	_, err = cfg.Options()
+	if err == nil {
+		t.Fatal("expected invalid config")
+	}
+	for _, problem := range []string{"output.dir", "statuses.draft", "transforms", "filters.from", "fetchTimeout"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected error about %s: %v", problem, err)
+		}
+	}
+
+	f := filepath.Join(t.TempDir(), "bad.yaml")
+	err = ioutil.WriteFile(f, []byte("site:\n  domain: foo.com\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(f, &cfg); err == nil || !strings.Contains(err.Error(), "domain") {
+		t.Errorf("expected error on unknown field, got %v", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := DefaultSite()
+
+	post := doc.Items[3]
+	aliases := site.Aliases(post, DefaultPermalinks().URL(post))
+	expected := []string{
+		"/?p=4516",
+		"/2009/06/las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins/",
+	}
+	if strings.Join(aliases, " ") != strings.Join(expected, " ") {
+		t.Errorf("unexpected aliases for post: %v", aliases)
+	}
+
+	attachment := doc.Items[2]
+	aliases = site.Aliases(attachment, attachment.Slug)
+	expected = []string{
+		"/2009/06/26/epa-con-el-culo-al-aire/culo_al_aire/",
+		"/?attachment_id=4658",
+	}
+	if strings.Join(aliases, " ") != strings.Join(expected, " ") {
+		t.Errorf("unexpected aliases for attachment: %v", aliases)
+	}
+
+	renderer := ContentRenderer{Site: site}
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(post, &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), `aliases: ["/2009/06/las-plataformas`) || strings.Contains(buff.String(), "?p=") {
+		t.Errorf("missing aliases in front matter: %s", buff.String())
+	}
+}
+
+func TestWriteRedirects(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := DefaultSite()
+	redirects := append(site.mediaRedirects(), NewTaxonomies(doc).Used(doc.Items[3:]).termRedirects()...)
+	redirects = uniqueRedirects(append(redirects, site.itemRedirects(doc.Items[3], DefaultPermalinks().URL(doc.Items[3]))...))
+
+	expected := map[string][]string{
+		RedirectsNetlify: {
+			"/  p=4516  /2009/06/16/las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins/  301",
+			"/category/algoreros/  /categories/algoreros/  301",
+			"/category/cambio-climatico/calentamiento-global/  /categories/calentamiento-global/  301",
+			"/category/calentamiento-global/  /categories/calentamiento-global/  301",
+			"/wp-content/uploads/*  /media/:splat  301",
+		},
+		RedirectsNginx: {
+			`"/?p=4516" "/2009/06/16/las-plataformas`,
+			`"~^/files/(?<wp_rest>.*)$" "/media/$wp_rest";`,
+		},
+		RedirectsApache: {
+			"RewriteCond %{QUERY_STRING} ^p=4516$\nRewriteRule ^$ /2009/06/16/las-plataformas",
+			"RewriteRule ^tag/cambio-climatico/$ /tags/cambio-climatico/? [R=301,NE,L]",
+		},
+		RedirectsCaddy: {
+			"\tpath /\n\tquery p=4516\n}\nredir @wp_redirect_",
+			"redir /2009/06/las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins/ /2009/06/16/",
+		},
+	}
+	for format, fragments := range expected {
+		var buff bytes.Buffer
+		err := WriteRedirects(&buff, format, redirects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, frag := range fragments {
+			if !strings.Contains(buff.String(), frag) {
+				t.Errorf("%s: expected to find %s in:\n%s", format, frag, buff.String())
+			}
+		}
+	}
+
+	var buff bytes.Buffer
+	err = WriteRedirects(&buff, RedirectsApache, []Redirect{{From: "/2009/06/cl%C3%ADma-y-a%C3%B1os/", To: "/clima/a%C3%B1os/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), `RewriteRule ^2009/06/clíma-y-años/$ /clima/a\%C3\%B1os/? [R=301,NE,L]`) {
+		t.Errorf("expected the accented path to be decoded: %s", buff.String())
+	}
+
+	if err := WriteRedirects(ioutil.Discard, "iis", redirects); err == nil {
+		t.Errorf("expected error on unknown format")
+	}
+}
+
+func TestResolveLinks(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := DefaultSite()
+	post := doc.Items[3]
+	page := Item{ID: 2, Slug: "about", PostType: "page", Link: "http://plazamoyua.com/about/"}
+	layout, _ := NewLayout(DefaultPermalinks(), DefaultStatusPolicy(), nil, nil, []Item{post, page})
+
+	content := `<a href="http://plazamoyua.wordpress.com/?p=4516#more-4516">more</a>
+<a title="x" href='https://plazamoyua.com/2009/06/las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins/'>month link</a>
+<a href="/about">about</a>
+<a href="https://plazamoyua.com/2010/01/01/gone/">gone</a>
+<a href="https://plazamoyua.com/category/co2/">co2</a>
+<a href="http://example.com/about/">elsewhere</a>
+<a href="http://plazamoyua.com/moyua6jpg/">photo page</a>
+<a href="/?attachment_id=16">photo</a>`
+
+	links, err := NewLinkIndex(site, []Item{post, page}, NewAttachments(doc.Items), layout.URL, layout.ContentFile,
+		LinksURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postURL := "/2009/06/16/las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins/"
+	out := resolveLinks(TransformContext{Item: &page, Site: &site, Links: links}, content)
+	for _, frag := range []string{
+		`<a href="` + postURL + `#more-4516">more</a>`,
+		`<a title="x" href="` + postURL + `">month link</a>`,
+		`<a href="/about/">about</a>`,
+		`<a href="https://plazamoyua.com/2010/01/01/gone/">gone</a>`,
+		`<a href="https://plazamoyua.com/category/co2/">co2</a>`,
+		`<a href="http://example.com/about/">elsewhere</a>`,
+		`<a href="/?attachment_id=16">photo</a>`,
+	} {
+		if !strings.Contains(out, frag) {
+			t.Errorf("expected to find %s in:\n%s", frag, out)
+		}
+	}
+	broken := links.Unresolved()
+	if len(broken) != 1 || broken[0] != (BrokenLink{ItemID: 2, Href: "https://plazamoyua.com/2010/01/01/gone/"}) {
+		t.Errorf("unexpected unresolved links: %v", broken)
+	}
+
+	links, err = NewLinkIndex(site, []Item{post, page}, nil, layout.URL, layout.ContentFile, LinksRelref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = resolveLinks(TransformContext{Item: &page, Site: &site, Links: links}, content)
+	if !strings.Contains(out, `<a href="{{< relref "/page/about/index.md" >}}">about</a>`) {
+		t.Errorf("expected relref link in:\n%s", out)
+	}
+	out = resolveLinks(TransformContext{Item: &page, Site: &site, Links: links, Comment: &Comment{}}, content)
+	if !strings.Contains(out, `<a href="/about/">about</a>`) {
+		t.Errorf("expected plain link in comment:\n%s", out)
+	}
+}
+
+func TestPermalinks(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := doc.Items[3]
+	draft := post
+	draft.PostDate = "0000-00-00 00:00:00"
+
+	permalinks := Permalinks{
+		"post": "/%category%/%year%/%post_id%-%postname%/",
+		"page": "%author%/%postname%",
+	}
+	if err := permalinks.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	slug := "las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins"
+	cases := []struct {
+		permalinks Permalinks
+		it         Item
+		expected   string
+	}{
+		{nil, post, "2009/06/16/" + slug},
+		{permalinks, post, "algoreros/2009/4516-" + slug},
+		{permalinks, draft, "algoreros/4516-" + slug},
+		{permalinks, Item{PostType: "page", Slug: "about", Author: "soil"}, "soil/about"},
+		{permalinks, Item{PostType: "portfolio", Slug: "work"}, "portfolio/work"},
+	}
+	for _, c := range cases {
+		if p := c.permalinks.Path(c.it); p != c.expected {
+			t.Errorf("expected path %s, got %s", c.expected, p)
+		}
+	}
+	if u := permalinks.URL(post); u != "/algoreros/2009/4516-"+slug+"/" {
+		t.Errorf("unexpected URL %s", u)
+	}
+	if d := (Layout{Permalinks: permalinks}).Dir(post); d != filepath.Join("post", "algoreros", "2009", "4516-"+slug) {
+		t.Errorf("unexpected dir %s", d)
+	}
+
+	page := Item{PostType: "page", Slug: "about", Author: "plazaeme"}
+	hidden := Layout{Permalinks: permalinks, Authors: NewAuthors(doc, AuthorOptions{HideLogins: true})}
+	if u := hidden.URL(page); u != "/plaza-moyua/about/" {
+		t.Errorf("expected the author's slug in the URL, got %s", u)
+	}
+
+	nested := Layout{Permalinks: permalinks, Categories: NewTaxonomies(doc).Categories}
+	if u := nested.URL(post); u != "/cambio-climatico/2009/4516-"+slug+"/" {
+		t.Errorf("expected the category with the lowest ID in the URL, got %s", u)
+	}
+	child := Item{PostType: "post", Slug: "deshielo", PostDate: "2009-06-16 10:00:00",
+		Categories: []Category{{Domain: "category", NiceName: "calentamiento-global"}}}
+	if u := nested.URL(child); u != "/cambio-climatico/calentamiento-global/2009/0-deshielo/" {
+		t.Errorf("expected the category's ancestors in the URL, got %s", u)
+	}
+
+	work := Layout{Permalinks: permalinks}
+	if d := work.Dir(Item{PostType: "portfolio", Slug: "work"}); d != filepath.Join("portfolio", "work") {
+		t.Errorf("unexpected dir for a type without a pattern: %s", d)
+	}
+
+	items := []Item{
+		{ID: 1, PostType: "page", Slug: "work", Status: "publish"},
+		{ID: 2, PostType: "portfolio", Slug: "work", Status: "publish"},
+		{ID: 3, PostType: "portfolio", Slug: "work", Status: "draft"},
+	}
+	flat := Permalinks{"page": "%pagename%", "portfolio": "%postname%"}
+	_, warnings := NewLayout(flat, DefaultStatusPolicy(), nil, nil, items)
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "same URL /work/ as page 1") ||
+		!strings.Contains(warnings[1], "same directory") {
+		t.Errorf("unexpected warnings for clashing items: %v", warnings)
+	}
+	links, err := NewLinkIndex(DefaultSite(), items[:2], nil, flat.URL,
+		func(i Item) string { return i.PostType }, LinksRelref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved, _ := links.Resolve("/work/", 9, true); !strings.Contains(resolved, `"/page"`) {
+		t.Errorf("expected the first item to keep its URL, got %s", resolved)
+	}
+
+	if err := (Permalinks{"post": "%year%/%slug%"}).Validate(); err == nil {
+		t.Errorf("expected error on unknown token")
+	}
+	if err := (Permalinks{"post": "%year%/%monthnum%"}).Validate(); err == nil {
+		t.Errorf("expected error on pattern without the post name")
+	}
+}
+
+func TestPageHierarchy(t *testing.T) {
+	pages := []Item{
+		{ID: 1, Slug: "about", PostType: "page"},
+		{ID: 2, Slug: "team", PostType: "page", PostParent: 1, MenuOrder: 3},
+		{ID: 3, Slug: "alice", PostType: "page", PostParent: 2},
+		{ID: 4, Slug: "lost", PostType: "page", PostParent: 99},
+		{ID: 5, Slug: "chicken", PostType: "page", PostParent: 6},
+		{ID: 6, Slug: "egg", PostType: "page", PostParent: 5},
+		{ID: 7, Slug: "hello", PostType: "post", PostParent: 1, PostDate: "2009-06-16 18:57:27"},
+	}
+	layout, warnings := NewLayout(DefaultPermalinks(), DefaultStatusPolicy(), nil, nil, pages)
+	if len(warnings) != 2 ||
+		!strings.Contains(warnings[0], "parent 99 is not exported") ||
+		!strings.Contains(warnings[1], "cycle (chicken > egg > chicken)") {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	expected := []struct{ url, file string }{
+		{"/about/", "page/about/_index.md"},
+		{"/about/team/", "page/about/team/_index.md"},
+		{"/about/team/alice/", "page/about/team/alice/index.md"},
+		{"/lost/", "page/lost/index.md"},
+		{"/chicken/", "page/chicken/_index.md"},
+		{"/chicken/egg/", "page/chicken/egg/index.md"},
+		{"/2009/06/16/hello/", "post/2009/06/16/hello/index.md"},
+	}
+	for n, it := range pages {
+		if u := layout.URL(it); u != expected[n].url {
+			t.Errorf("%s: expected URL %s, got %s", it.Slug, expected[n].url, u)
+		}
+		if f := filepath.ToSlash(layout.ContentFile(it)); f != expected[n].file {
+			t.Errorf("%s: expected file %s, got %s", it.Slug, expected[n].file, f)
+		}
+	}
+
+	renderer := ContentRenderer{Layout: layout}
+	var buff bytes.Buffer
+	err := renderer.ToMarkdown(pages[1], &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), "weight: 3") || !strings.Contains(buff.String(), `url: "/about/team/"`) {
+		t.Errorf("unexpected front matter: %s", buff.String())
+	}
+}
+
+func TestMoreAndPages(t *testing.T) {
+	post := Item{
+		ID: 7, Slug: "hello", PostType: "post", PostDate: "2009-06-16 18:57:27",
+		Encodeds: []Encoded{
+			{XMLName: xml.Name{Space: contentSpace}, Data: "intro<!--more Sigue leyendo-->rest<!--more-->\n" +
+				"<!--nextpage-->\n<!-- wp:nextpage -->\n<!--nextpage-->\n<!-- /wp:nextpage -->second"},
+			{XMLName: xml.Name{Space: excerptSpace}, Data: `a "short" one`},
+		},
+	}
+	renderer := ContentRenderer{
+		Pipeline:     DefaultPipeline(),
+		Site:         DefaultSite(),
+		ExcerptField: "description",
+	}
+	renderer.Layout, _ = NewLayout(DefaultPermalinks(), DefaultStatusPolicy(), nil, nil, []Item{post})
+
+	var buff bytes.Buffer
+	err := renderer.ToMarkdown(post, &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), "description: \"a \\\"short\\\" one\"\n") ||
+		!strings.Contains(buff.String(), "intro\n\n<!--more-->\n\nrest\n<!--nextpage-->\n<!--nextpage-->second") {
+		t.Errorf("unexpected markdown: %s", buff.String())
+	}
+
+	docs, err := renderer.ToMarkdownPages(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(docs))
+	}
+	first, second := string(docs[0]), string(docs[1])
+	if !strings.Contains(first, "part: 1\nparts: 2\n") || !strings.Contains(first, "description:") ||
+		strings.Contains(first, "second") {
+		t.Errorf("unexpected first page: %s", first)
+	}
+	if !strings.Contains(second, `url: "/2009/06/16/hello/2/"`) || !strings.Contains(second, "list: never") ||
+		strings.Contains(second, "description:") || !strings.Contains(second, "\nsecond") {
+		t.Errorf("unexpected second page: %s", second)
+	}
+	if dir := filepath.ToSlash(renderer.Layout.PartDir(post, 2)); dir != "post/2009/06/16/hello-2" {
+		t.Errorf("unexpected dir for the second page: %s", dir)
+	}
+}
+
+func TestDates(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := DefaultSite()
+	site.Timezone = "Europe/Madrid"
+	renderer := ContentRenderer{Site: site}
+
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(doc.Items[3], &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`date: "2009-06-16T19:57:27+02:00"`, `lastmod: "2009-06-17T05:53:54+02:00"`} {
+		if !strings.Contains(buff.String(), line) {
+			t.Errorf("expected to find %s in %s", line, buff.String())
+		}
+	}
+
+	draft := Item{PostDate: "2009-01-02 10:00:00", PostDateGMT: zeroWPDate}
+	if d := isoDate(site.PostTime(draft)); d != "2009-01-02T10:00:00+01:00" {
+		t.Errorf("unexpected date for a draft: %s", d)
+	}
+	if !site.ModifiedTime(draft).IsZero() {
+		t.Errorf("expected no modification date")
+	}
+
+	html, err := renderer.ThreadToHTML(doc.Items[2], ThreadComments(doc.Items[2].Comments)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<time class="date" datetime="2010-11-18T23:11:36&#43;01:00">2010-11-18 23:11</time>`) {
+		t.Errorf("unexpected comment date: %s", html)
+	}
+	renderer.Site.Timezone = ""
+	html, err = renderer.ThreadToHTML(doc.Items[2], ThreadComments(doc.Items[2].Comments)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<time class="date" datetime="2010-11-18T22:11:36Z">2010-11-18 22:11</time>`) {
+		t.Errorf("expected the comment date in UTC: %s", html)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Site.Timezone = "Mars/Olympus_Mons"
+	if _, err := cfg.Options(); err == nil || !strings.Contains(err.Error(), "site.timezone") {
+		t.Errorf("expected a timezone error, got %v", err)
+	}
+}
+
+func TestTerms(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Categories) != 2 || len(doc.Tags) != 1 || doc.Categories[1].Parent != "cambio-climatico" {
+		t.Fatalf("unexpected channel terms: %+v %+v", doc.Categories, doc.Tags)
+	}
+
+	all := NewTaxonomies(doc)
+	if all.Categories["algoreros"].Name != "algoreros" || all.Tags["cambio-climatico"].Name != "Cambio Climático" {
+		t.Errorf("unexpected terms: %+v", all)
+	}
+	used := all.Used([]Item{{Categories: []Category{{Domain: "category", NiceName: "calentamiento-global"}}}})
+	if len(used.Categories) != 2 || len(used.Tags) != 0 {
+		t.Errorf("expected a category and its parent, got %+v", used)
+	}
+
+	var buff bytes.Buffer
+	err = all.Categories.WriteTermPage(&buff, TaxonomyCategories, all.Categories["calentamiento-global"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `---
+title: "Calentamiento global"
+parent: "cambio-climatico"
+aliases: ["/category/cambio-climatico/calentamiento-global/", "/categories/cambio-climatico/calentamiento-global/"]
+---
+`
+	if buff.String() != expected {
+		t.Errorf("unexpected term page: %s", buff.String())
+	}
+	buff.Reset()
+	err = all.Categories.WriteTermPage(&buff, TaxonomyCategories, all.Categories["cambio-climatico"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), `title: "Cambio Climático"`+"\n"+`description: "Lo que dicen del clima"`) {
+		t.Errorf("unexpected term page: %s", buff.String())
+	}
+	if dir := TermDir(TaxonomyTags, all.Tags["cambio-climatico"]); dir != "tags/cambio-climatico" {
+		t.Errorf("unexpected term dir: %s", dir)
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Authors) != 1 || doc.Authors[0].DisplayName != "Plaza Moyua" {
+		t.Fatalf("unexpected channel authors: %+v", doc.Authors)
+	}
+
+	authors := NewAuthors(doc, AuthorOptions{
+		Profiles: map[string]AuthorProfile{"plazaeme": {Bio: "Escribe sobre el *clima*."}},
+	})
+	if authors["plazaeme"] != (Author{Login: "plazaeme", Nicename: "plazaeme", Slug: "plazaeme", Name: "Plaza Moyua", Bio: "Escribe sobre el *clima*."}) ||
+		authors["soil"].Name != "soil" {
+		t.Errorf("unexpected authors: %+v", authors)
+	}
+	odd := NewAuthors(RSS{Authors: []WPAuthor{{Login: "Ana María@example.com"}}}, AuthorOptions{})
+	if author := odd["Ana María@example.com"]; author.Slug != "ana-maria-example-com" || author.Nicename != author.Slug {
+		t.Errorf("expected the login to be slugified: %+v", author)
+	}
+	renderer := ContentRenderer{Site: DefaultSite(), Authors: authors}
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(doc.Items[3], &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), "author: \"Plaza Moyua\"\nauthors: [\"plazaeme\"]\n") {
+		t.Errorf("unexpected front matter: %s", buff.String())
+	}
+	buff.Reset()
+	err = WriteAuthorPage(&buff, authors["plazaeme"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `---
+title: "Plaza Moyua"
+aliases: ["/author/plazaeme/"]
+---
+
+Escribe sobre el *clima*.
+`
+	if buff.String() != expected {
+		t.Errorf("unexpected author page: %s", buff.String())
+	}
+
+	hidden := NewAuthors(doc, AuthorOptions{HideLogins: true})
+	if len(hidden) != 1 || hidden["plazaeme"].Slug != "plaza-moyua" {
+		t.Errorf("unexpected authors with hidden logins: %+v", hidden)
+	}
+	renderer.Authors = hidden
+	buff.Reset()
+	err = renderer.ToMarkdown(doc.Items[0], &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buff.String(), "soil") {
+		t.Errorf("login was not hidden: %s", buff.String())
+	}
+	buff.Reset()
+	err = WriteAuthorPage(&buff, hidden["plazaeme"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buff.String(), "plazaeme") {
+		t.Errorf("login was not hidden: %s", buff.String())
+	}
+}
+
+func TestFeaturedImage(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, found := doc.Items[3].Meta("_thumbnail_id"); !found || id != "4658" {
+		t.Errorf("unexpected _thumbnail_id: %q", id)
+	}
+	if _, found := doc.Items[3].Meta("_edit_last"); !found {
+		t.Errorf("expected to find all the postmeta")
+	}
+
+	var logs bytes.Buffer
+	renderer := ContentRenderer{
+		Site:        DefaultSite(),
+		Attachments: NewAttachments(doc.Items),
+		Logger:      log.New(&logs, "", 0),
+	}
+	var buff bytes.Buffer
+	err = renderer.ToMarkdown(doc.Items[3], &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), "featured_image: \"/media/2009/06/culo_al_aire.jpg\"\nimages: [\"/media/2009/06/culo_al_aire.jpg\"]\n") {
+		t.Errorf("unexpected front matter: %s", buff.String())
+	}
+
+	delete(renderer.Attachments, 4658)
+	buff.Reset()
+	err = renderer.ToMarkdown(doc.Items[3], &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buff.String(), "featured_image") || !strings.Contains(logs.String(), "featured image 4658") {
+		t.Errorf("unexpected missing featured image: %s %s", buff.String(), logs.String())
+	}
+
+	site := Site{Domains: []string{"example.com"}, MediaPath: "/media"}
+	if u := site.MediaURL("https://example.com/wp-content/uploads/2020/01/a.png"); u != "/media/2020/01/a.png" {
+		t.Errorf("unexpected media URL: %s", u)
+	}
+}
+
+func TestBundleMedia(t *testing.T) {
+	site := DefaultSite()
+	site.Domains = append(site.Domains, "example.com")
+	for ref, expected := range map[string]string{
+		"http://plazamoyua.files.wordpress.com/2009/06/dipuccio-2.jpg?w=350&amp;h=306": "2009/06/dipuccio-2.jpg",
+		"https://example.com/wp-content/uploads/2020/01/a%20b.pdf":                     "2020/01/a b.pdf",
+		"/wp-content/uploads/2020/01/c.png":                                            "2020/01/c.png",
+		"/media/2009/06/d.jpg":                                                         "2009/06/d.jpg",
+		"/media/../../etc/passwd.txt":                                                  "etc/passwd.txt",
+		"https://example.com/2009/06/16/post/":                                         "",
+		"http://wattsupwiththat.files.wordpress.com/2009/04/wilkins_recycled.jpg":      "",
+		"photo.jpg": "",
+	} {
+		if p, _ := site.UploadPath(ref); p != expected {
+			t.Errorf("%s: expected upload path %q, got %q", ref, expected, p)
+		}
+	}
+
+	media := NewBundleMedia()
+	if a, b := media.Add("2009/06/a.jpg"), media.Add("2010/01/a.jpg"); a != "a.jpg" || b != "a-2.jpg" {
+		t.Errorf("unexpected names: %s %s", a, b)
+	}
+
+	attachments := NewAttachments([]Item{
+		{ID: 1, PostType: "attachment", PostParent: 9, MenuOrder: 2, AttachmentURL: "/wp-content/uploads/b.jpg"},
+		{ID: 2, PostType: "attachment", PostParent: 9, MenuOrder: 1, AttachmentURL: "/wp-content/uploads/a.jpg"},
+		{ID: 3, PostType: "attachment", PostParent: 8, AttachmentURL: "/wp-content/uploads/c.jpg"},
+		{ID: 9, PostType: "post"},
+	})
+	if children := attachments.Children(Item{ID: 9}); len(children) != 2 || children[0].ID != 2 {
+		t.Errorf("unexpected attachments: %+v", children)
+	}
+
+	uploads := t.TempDir()
+	for _, file := range []string{"2009/06/dipuccio-2.jpg", "2009/06/culo_al_aire.jpg"} {
+		err := os.MkdirAll(filepath.Join(uploads, filepath.Dir(file)), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(uploads, file), []byte(file), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	outdir := t.TempDir()
+	var logs bytes.Buffer
+	err := Export(context.Background(), Options{
+		XMLFile: "testdata/testWpExport.xml",
+		OutDir:  outdir,
+		Filter:  Filter{Types: map[string]bool{"post": true}},
+		Media:   UploadsDir(uploads),
+		Log:     &logs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(outdir, "post", "2009", "06", "16",
+		"las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins")
+	md, err := ioutil.ReadFile(filepath.Join(dir, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frag := range []string{`src="dipuccio-2.jpg"`, `src="wilkins_recycled.jpg"`,
+		`featured_image: "culo_al_aire.jpg"`,
+		`images: ["/2009/06/16/las-plataformas-de-hielo-de-la-antartida-estables-lo-siento-por-fans-de-wilkins/culo_al_aire.jpg"]`} {
+		if !strings.Contains(string(md), frag) {
+			t.Errorf("expected to find %s in %s", frag, md)
+		}
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "dipuccio-2.jpg")); err != nil || string(b) != "2009/06/dipuccio-2.jpg" {
+		t.Errorf("media was not copied: %v", err)
+	}
+	if !strings.Contains(logs.String(), "copied 2 media files") ||
+		!strings.Contains(logs.String(), "WARN: missing media in item 4516: 2009/04/wilkins_recycled.jpg") {
+		t.Errorf("unexpected log: %s", logs.String())
+	}
+}
+
+func TestInventory(t *testing.T) {
+	var doc RSS
+	err := xml.Unmarshal([]byte(testXML), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploads := t.TempDir()
+	for _, file := range []string{"2009/06/dipuccio-2.jpg", "old/unused.png"} {
+		err := os.MkdirAll(filepath.Join(uploads, filepath.Dir(file)), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(uploads, file), nil, 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := DefaultSite().Inventory(doc.Items, uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buff bytes.Buffer
+	err = WriteInventoryCSV(&buff, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `status,class,upload_path,url,items,sources
+unchecked,third-party,,http://wattsupwiththat.files.wordpress.com/2009/04/wilkins_recycled.jpg,4516,content
+missing,remote,2007/01/moyua6.jpg,http://plazamoyua.files.wordpress.com/2007/01/moyua6.jpg,16,content attachment
+missing,remote,2007/01/tumbago.jpg,https://plazamoyua.files.wordpress.com/2007/01/tumbago.jpg,47,attachment
+missing,remote,2009/04/wilkins_recycled.jpg,http://plazamoyua.files.wordpress.com/2009/04/wilkins_recycled.jpg,4516,content
+missing,remote,2009/06/culo_al_aire.jpg,https://plazamoyua.files.wordpress.com/2009/06/culo_al_aire.jpg,4658,attachment
+found,remote,2009/06/dipuccio-2.jpg,http://plazamoyua.files.wordpress.com/2009/06/dipuccio-2.jpg,4516,content
+unreferenced,,old/unused.png,,,
+`
+	if buff.String() != expected {
+		t.Errorf("unexpected inventory:\n%s", buff.String())
+	}
+
+	buff.Reset()
+	err = WriteInventoryJSON(&buff, entries[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), `"class": "third-party"`) || !strings.Contains(buff.String(), `"items": [`) {
+		t.Errorf("unexpected JSON: %s", buff.String())
+	}
+
+	site := Site{Domains: []string{"example.com"}, MediaPath: "/media"}
+	entries, err = site.Inventory([]Item{{ID: 1, Comments: []Comment{{Content: "mira /wp-content/uploads/a.png y " +
+		"https://example.com/wp-content/uploads/b.pdf."}}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].UploadPath != "b.pdf" || entries[0].Class != MediaLocal ||
+		entries[0].Status != MediaUnchecked || entries[0].Sources[0] != SourceComment {
+		t.Errorf("unexpected inventory of comments: %+v", entries)
+	}
+}
+
+func TestOriginalImages(t *testing.T) {
+	site := DefaultSite()
+	site.Domains = append(site.Domains, "example.com")
+	originals := map[string]bool{"2020/01/photo.jpg": true, "2020/01/poster-1024x768.png": true}
+	for ref, expected := range map[string]ImageVariant{
+		"https://example.com/wp-content/uploads/2020/01/photo-300x200.jpg":           {"2020/01/photo.jpg", 300, 200},
+		"https://plazamoyua.files.wordpress.com/2020/01/photo.jpg?w=640&amp;h=480":   {"2020/01/photo.jpg", 640, 480},
+		"https://plazamoyua.files.wordpress.com/2020/01/photo.jpg?resize=800%2C600":  {"2020/01/photo.jpg", 800, 600},
+		"https://example.com/wp-content/uploads/2020/01/poster-1024x768.png":         {},
+		"https://example.com/wp-content/uploads/2020/01/poster-1024x768-150x150.png": {"2020/01/poster-1024x768.png", 150, 150},
+		"https://example.com/wp-content/uploads/2020/01/unknown-300x200.jpg":         {},
+		"https://example.com/wp-content/uploads/2020/01/photo.jpg":                   {},
+	} {
+		variant, ok := site.ImageVariant(ref, originals)
+		if ok != (expected != ImageVariant{}) || (ok && variant != expected) {
+			t.Errorf("%s: expected variant %+v, got %+v (%v)", ref, expected, variant, ok)
+		}
+	}
+
+	tc := TransformContext{Item: &Item{}, Site: &site, Originals: originals}
+	in := `<a href="https://example.com/wp-content/uploads/2020/01/photo.jpg"><img class="x" ` +
+		`src="https://example.com/wp-content/uploads/2020/01/photo-300x200.jpg" width="300" ` +
+		`srcset="https://example.com/wp-content/uploads/2020/01/photo-300x200.jpg 300w, ` +
+		`https://example.com/wp-content/uploads/2020/01/photo-1024x683.jpg 1024w" sizes="(max-width: 300px) 100vw, 300px" /></a>`
+	expected := `<a href="https://example.com/wp-content/uploads/2020/01/photo.jpg"><img class="x" ` +
+		`src="https://example.com/wp-content/uploads/2020/01/photo.jpg" width="300" /></a>`
+	if out := originalImages(tc, in); out != expected {
+		t.Errorf("unexpected images: %s", out)
+	}
+	site.ImageDimensions = true
+	expected = `<a href="https://example.com/wp-content/uploads/2020/01/photo.jpg"><img class="x" ` +
+		`src="https://example.com/wp-content/uploads/2020/01/photo.jpg" width="300" height="200" /></a>`
+	if out := originalImages(tc, in); out != expected {
+		t.Errorf("unexpected images with dimensions: %s", out)
+	}
+	in = `<img src='http://plazamoyua.files.wordpress.com/2009/06/a.jpg?w=350&amp;h=306'>`
+	if out := originalImages(tc, in); out != `<img src="http://plazamoyua.files.wordpress.com/2009/06/a.jpg" width="350" height="306">` {
+		t.Errorf("unexpected images with dimensions: %s", out)
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/wp-content/uploads/2020/01/a.jpg", "/wp-content/uploads/2020/01/copy.jpg":
+			fmt.Fprint(w, "image a")
+		case "/wp-content/uploads/2020/01/flaky.jpg":
+			if n < 3 {
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "flaky")
+		case "/wp-content/uploads/2020/01/big.jpg":
+			fmt.Fprint(w, "far too large for the limit")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	fetcher := &Fetcher{
+		Dir:        dir,
+		BaseURLs:   []string{server.URL + "/cdn/", server.URL + "/wp-content/uploads/"},
+		Jobs:       2,
+		RetryDelay: time.Millisecond,
+		MaxSize:    20,
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := fetcher.Open(context.Background(), "2020/01/a.jpg")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer r.Close()
+			if b, _ := ioutil.ReadAll(r); string(b) != "image a" {
+				t.Errorf("unexpected contents: %s", b)
+			}
+		}()
+	}
+	wg.Wait()
+	if requests["/wp-content/uploads/2020/01/a.jpg"] != 1 || requests["/cdn/2020/01/a.jpg"] != 1 {
+		t.Errorf("expected a single download, got %v", requests)
+	}
+
+	var logs bytes.Buffer
+	stats, err := fetcher.FetchAll(context.Background(),
+		[]string{"2020/01/a.jpg", "2020/01/copy.jpg", "2020/01/flaky.jpg", "2020/01/big.jpg", "2020/01/gone.jpg"}, &logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (FetchStats{Cached: 1, Fetched: 2, Missing: 1, Failed: 1}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if !strings.Contains(logs.String(), "file too large") || requests["/wp-content/uploads/2020/01/flaky.jpg"] != 3 {
+		t.Errorf("unexpected fetch: %s %v", logs.String(), requests)
+	}
+	if _, err := fetcher.Open(context.Background(), "2020/01/gone.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing file, got %v", err)
+	}
+	objects, _ := filepath.Glob(filepath.Join(dir, "objects", "*", "*"))
+	if len(objects) != 2 {
+		t.Errorf("expected the same contents to be stored once, got %v", objects)
+	}
+	err = fetcher.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new fetcher resumes from the cache
+	server.Close()
+	resumed := &Fetcher{Dir: dir, BaseURLs: fetcher.BaseURLs, RetryDelay: time.Millisecond}
+	defer resumed.Close()
+	r, err := resumed.Open(context.Background(), "2020/01/flaky.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if _, err := resumed.Open(context.Background(), "2020/01/gone.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing file, got %v", err)
+	}
+	if _, err := resumed.Open(context.Background(), "2020/01/big.jpg"); !errors.Is(err, ErrMediaUnavailable) {
+		t.Errorf("expected failed downloads to be tried again, got %v", err)
+	}
+
+	// the export goes on without the files that could not be downloaded
+	copier := &mediaCopier{source: resumed}
+	media := NewBundleMedia()
+	media.Add("2020/01/big.jpg")
+	media.Add("2020/01/flaky.jpg")
+	if err := copier.copyAll(context.Background(), Item{ID: 7}, media, t.TempDir()); err != nil {
+		t.Errorf("expected unavailable media to be reported, got %v", err)
+	}
+	if missing := copier.Missing(); len(missing) != 1 || !errors.Is(missing[0].Err, ErrMediaUnavailable) || copier.copied != 1 {
+		t.Errorf("unexpected missing media: %v", missing)
+	}
+
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer stalled.Close()
+	slow := &Fetcher{Dir: t.TempDir(), BaseURLs: []string{stalled.URL + "/"}, Attempts: 1, Timeout: 50 * time.Millisecond}
+	defer slow.Close()
+	if _, err := slow.Open(context.Background(), "2020/01/a.jpg"); !errors.Is(err, ErrMediaUnavailable) {
+		t.Errorf("expected a stalled download to time out, got %v", err)
+	}
+
+	bases := Site{Domains: []string{"example.com"}}.UploadBaseURLs()
+	if strings.Join(bases, " ") != "https://example.com/wp-content/uploads/ http://example.com/wp-content/uploads/" {
+		t.Errorf("unexpected base URLs: %v", bases)
+	}
+}
+
+func TestFigures(t *testing.T) {
+	site := DefaultSite()
+	attachments := NewAttachments([]Item{{
+		ID: 123, PostType: "attachment", Link: "https://plazamoyua.com/2020/01/01/post/photo/",
+		AttachmentURL: "https://plazamoyua.files.wordpress.com/2020/01/photo.jpg",
+		PostMeta:      []PostMeta{{MetaKey: "_wp_attachment_image_alt", MetaValue: MetaValue{Value: `un "ejemplo"`}}},
+	}})
+	tc := TransformContext{Item: &Item{}, Site: &site, Attachments: attachments}
+
+	in := `<p>[caption id="attachment_123" align="alignright" width="300"]` +
+		`<a href="/2020/01/01/post/photo/"><img class="size-medium wp-image-123" src="/media/2020/01/photo.jpg" alt="" width="300" height="200" /></a>` +
+		` Una <em>foto</em>, "dicen"[/caption]</p>`
+	expected := `<p>{{< figure src="/media/2020/01/photo.jpg" link="/media/2020/01/photo.jpg" alt="un &#34;ejemplo&#34;" ` +
+		`caption="Una <em>foto</em>, \"dicen\"" class="align-right" width="300" height="200" >}}</p>`
+	if out := convertFigures(tc, in); out != expected {
+		t.Errorf("unexpected caption:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	in = `<img class="alignleft wp-image-9" src="a.jpg" alt="a" /> y <img src="smiley.gif" alt=":)" />`
+	expected = `{{< figure src="a.jpg" alt="a" class="align-left" >}} y <img src="smiley.gif" alt=":)" />`
+	if out := convertFigures(tc, in); out != expected {
+		t.Errorf("unexpected images: %s", out)
+	}
+
+	tc.Comment = &Comment{}
+	in = `[caption align="aligncenter" caption="Texto"]<img class="wp-image-123" src="b.jpg">[/caption]`
+	expected = `<figure class="align-center"><img src="b.jpg" alt="un &#34;ejemplo&#34;"><figcaption>Texto</figcaption></figure>`
+	if out := convertFigures(tc, in); out != expected {
+		t.Errorf("unexpected figure in a comment: %s", out)
+	}
+}
+
+func TestGallery(t *testing.T) {
+	site := DefaultSite()
+	attachments := NewAttachments([]Item{
+		{ID: 1, PostType: "attachment", PostParent: 9, MenuOrder: 2, AttachmentURL: "https://plazamoyua.files.wordpress.com/2020/01/b.jpg"},
+		{ID: 2, PostType: "attachment", PostParent: 9, MenuOrder: 1, AttachmentURL: "https://plazamoyua.files.wordpress.com/2020/01/a.jpg",
+			Encodeds: []Encoded{{XMLName: xml.Name{Space: excerptSpace}, Data: "La primera"}},
+			PostMeta: []PostMeta{{MetaKey: "_wp_attachment_image_alt", MetaValue: MetaValue{Value: "primera"}}}},
+		{ID: 3, PostType: "attachment", PostParent: 8, AttachmentURL: "https://plazamoyua.files.wordpress.com/2020/01/c.jpg"},
+	})
+	tc := TransformContext{Item: &Item{ID: 9}, Site: &site, Attachments: attachments}
+
+	expected := `<div class="gallery gallery-columns-2">
+<figure class="gallery-item"><a href="/media/2020/01/a.jpg"><img src="/media/2020/01/a.jpg" alt="primera"></a><figcaption>La primera</figcaption></figure>
+<figure class="gallery-item"><a href="/media/2020/01/b.jpg"><img src="/media/2020/01/b.jpg" alt=""></a></figure>
+</div>`
+	if out := renderGalleries(tc, `[gallery columns="2"]`); out != expected {
+		t.Errorf("unexpected gallery:\n%s", out)
+	}
+
+	tc.Media = NewBundleMedia()
+	expected = `<div class="gallery gallery-columns-3">
+<figure class="gallery-item"><img src="c.jpg" alt=""></figure>
+<figure class="gallery-item"><img src="b.jpg" alt=""></figure>
+</div>`
+	if out := renderGalleries(tc, `[gallery ids="3,1,99" link="none"]`); out != expected {
+		t.Errorf("unexpected gallery with ids:\n%s", out)
+	}
+	if files := tc.Media.Files(); len(files) != 2 {
+		t.Errorf("expected the images in the bundle, got %v", files)
+	}
+	if out := renderGalleries(tc, `[gallery exclude="1,2"]`); out != `[gallery exclude="1,2"]` {
+		t.Errorf("expected an empty gallery to be left alone, got %s", out)
+	}
+}
+
+func TestUnserializePHP(t *testing.T) {
+	for in, expected := range map[string]interface{}{
+		`N;`:                            nil,
+		`b:1;`:                          true,
+		`i:-42;`:                        int64(-42),
+		`d:0.5;`:                        0.5,
+		`s:6:"señor";`:                  "señor",
+		`s:5:"a";b;";`:                  `a";b;`,
+		`a:2:{i:0;s:1:"a";i:1;N;}`:      []interface{}{"a", nil},
+		`a:2:{i:1;s:1:"a";i:0;N;}`:      map[string]interface{}{"1": "a", "0": nil},
+		`a:1:{s:4:"file";s:5:"a.jpg";}`: map[string]interface{}{"file": "a.jpg"},
+		`O:8:"stdClass":2:{s:1:"a";i:1;s:4:"` + "\x00*\x00b" + `";a:0:{}}`: map[string]interface{}{"a": int64(1), "b": []interface{}{}},
+	} {
+		v, err := UnserializePHP(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if fmt.Sprintf("%#v", v) != fmt.Sprintf("%#v", expected) {
+			t.Errorf("%s: expected %#v, got %#v", in, expected, v)
+		}
+	}
+
+	for _, in := range []string{
+		``, `hello`, `s:10:"short";`, `a:2:{i:0;N;}`, `a:1:{i:0;N;`, `i:12`, `b:2;`, `a:99999999:{}`,
+		`s:-1:"";`, `a:1:{a:0:{};N;}`, `r:1;`, `N;N;`, strings.Repeat("a:1:{i:0;", 100) + "N;" + strings.Repeat("}", 100),
+	} {
+		if _, err := UnserializePHP(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+	if _, err := UnserializePHP("plain text"); err != ErrNotPHPSerialized {
+		t.Errorf("expected ErrNotPHPSerialized, got %v", err)
+	}
+
+	meta := `a:3:{s:5:"width";i:1024;s:6:"height";i:768;s:5:"sizes";a:1:{s:9:"thumbnail";a:1:{s:4:"file";s:17:"photo-150x150.jpg";}}}`
+	decoded, ok := DecodeMeta(meta).(map[string]interface{})
+	if !ok || decoded["width"] != int64(1024) {
+		t.Errorf("unexpected attachment metadata: %#v", decoded)
+	}
+	it := Item{PostMeta: []PostMeta{{MetaKey: "_wp_attachment_metadata", MetaValue: MetaValue{Value: meta}}}}
+	if v, found := it.MetaData("_wp_attachment_metadata"); !found || fmt.Sprint(v) != fmt.Sprint(decoded) {
+		t.Errorf("unexpected meta data: %#v", v)
+	}
+	if v := DecodeMeta("7372158"); v != "7372158" {
+		t.Errorf("expected plain values to be kept, got %#v", v)
+	}
+}
+
+func FuzzUnserializePHP(f *testing.F) {
+	for _, seed := range []string{
+		`N;`, `b:0;`, `i:7;`, `d:-1.5e3;`, `s:3:"abc";`, `a:2:{i:0;s:1:"a";s:1:"k";d:INF;}`,
+		`O:3:"Foo":1:{s:6:"` + "\x00Foo\x00x" + `";N;}`, `a:1:{i:0;a:1:{i:0;a:0:{}}}`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		v, err := UnserializePHP(in)
+		if err != nil && v != nil {
+			t.Errorf("%q: expected no value with the error %v", in, err)
+		}
+	})
+}
+
+func TestMetaFrontMatter(t *testing.T) {
+	meta := func(key, value string) PostMeta {
+		return PostMeta{MetaKey: key, MetaValue: MetaValue{Value: value}}
+	}
+	it := Item{PostMeta: []PostMeta{
+		meta("_yoast_wpseo_metadesc", "Sobre el clima: lo que dicen"),
+		meta("_yoast_wpseo_focuskw", "clima"),
+		meta("_aioseop_description", "otra descripción"),
+		meta("_edit_last", "1"),
+		meta("price", "10"),
+		meta("price", "12"),
+		meta("fuente", ""),
+		meta("datos", `a:2:{i:0;s:1:"a";i:1;s:1:"b";}`),
+		meta("title", "no"),
+		meta("resumen", "no"),
+	}}
+
+	m := MetaMapping{Presets: []string{"yoast", "aioseo"}}
+	fields := m.FrontMatter(it)
+	if len(fields) != 2 || fields["description"] != "Sobre el clima: lo que dicen" || fields["focusKeyword"] != "clima" {
+		t.Errorf("unexpected preset fields: %v", fields)
+	}
+
+	m = MetaMapping{
+		Fields: map[string]string{"price": "precio", "title": "title", "_yoast_wpseo_focuskw": "-"},
+		Custom: true,
+	}
+	lines, err := metaLines(m.FrontMatter(it, "resumen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "datos:\n    - a\n    - b\nprecio: \"10\""
+	if lines != expected {
+		t.Errorf("unexpected custom fields:\n%s\nexpected:\n%s", lines, expected)
+	}
+
+	m.Internal = true
+	fields = m.FrontMatter(it)
+	if fields["_edit_last"] != "1" || fields["_yoast_wpseo_metadesc"] == nil || fields["_yoast_wpseo_focuskw"] != nil {
+		t.Errorf("unexpected internal fields: %v", fields)
+	}
+
+	var doc RSS
+	if err := xml.Unmarshal([]byte(testXML), &doc); err != nil {
+		t.Fatal(err)
+	}
+	renderer := ContentRenderer{Site: DefaultSite(), Meta: MetaMapping{Fields: map[string]string{"_edit_last": "editor"}}}
+	var buff bytes.Buffer
+	if err := renderer.ToMarkdown(doc.Items[3], &buff); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buff.String(), "\neditor: \"7372158\"\n---\n") {
+		t.Errorf("expected the meta at the end of the front matter: %s", buff.String())
+	}
+
+	if err := (MetaMapping{Presets: []string{"rankmath"}}).Validate(); err == nil {
+		t.Errorf("expected an unknown preset to fail")
+	}
+}
+
+func TestEmbeds(t *testing.T) {
+	site := DefaultSite()
+	soundcloud := `<iframe src="https://w.soundcloud.com/player/?url=https%3A%2F%2Fsoundcloud.com%2Fa%2Fb"></iframe>`
+	item := Item{PostMeta: []PostMeta{
+		{MetaKey: "_oembed_12ae21c6da8ed49ec4095e2409a45f03", MetaValue: MetaValue{Value: "{{unknown}}"}},
+		{MetaKey: "_oembed_time_0c9d8e6f", MetaValue: MetaValue{Value: "1600000000"}},
+		{MetaKey: "_oembed_0c9d8e6f", MetaValue: MetaValue{Value: soundcloud}},
+		{MetaKey: oembedKey("https://youtu.be/dQw4w9WgXcQ"), MetaValue: MetaValue{Value: "<iframe>cached</iframe>"}},
+	}}
+	tc := TransformContext{Item: &item, Site: &site}
+
+	in := "Mirad:\n\nhttps://www.youtube.com/watch?feature=share&amp;v=dQw4w9WgXcQ\n\n" +
+		"<p>https://vimeo.com/76979871</p>\n" +
+		"[embed width=\"500\"]https://x.com/jack/status/20[/embed]\n" +
+		"https://soundcloud.com/a/b\n" +
+		"https://example.com/nada\n" +
+		"Un vídeo: https://youtu.be/dQw4w9WgXcQ"
+	expected := "Mirad:\n\n{{< youtube dQw4w9WgXcQ >}}\n\n" +
+		"{{< vimeo 76979871 >}}\n" +
+		"{{< tweet user=\"jack\" id=\"20\" >}}\n" +
+		soundcloud + "\n" +
+		"https://example.com/nada\n" +
+		"Un vídeo: https://youtu.be/dQw4w9WgXcQ"
+	if out := convertEmbeds(tc, in); out != expected {
+		t.Errorf("unexpected embeds:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	in = "<pre>\nhttps://youtu.be/dQw4w9WgXcQ\n</pre>\n<code>https://vimeo.com/76979871</code>\n" +
+		"<CODE>\nhttps://vimeo.com/76979871\n</CODE>\nhttps://vimeo.com/76979871"
+	expected = "<pre>\nhttps://youtu.be/dQw4w9WgXcQ\n</pre>\n<code>https://vimeo.com/76979871</code>\n" +
+		"<CODE>\nhttps://vimeo.com/76979871\n</CODE>\n{{< vimeo 76979871 >}}"
+	if out := convertEmbeds(tc, in); out != expected {
+		t.Errorf("expected preformatted URLs to be left alone, got:\n%s", out)
+	}
+
+	site.Embeds = map[string]string{"twitter": "x"}
+	in = "https://youtu.be/dQw4w9WgXcQ\nhttps://twitter.com/jack/statuses/20"
+	expected = "<iframe>cached</iframe>\n{{< x user=\"jack\" id=\"20\" >}}"
+	if out := convertEmbeds(tc, in); out != expected {
+		t.Errorf("unexpected embeds with other shortcodes:\n%s", out)
+	}
+
+	tc.Comment = &Comment{}
+	if out := convertEmbeds(tc, in); out != in {
+		t.Errorf("expected comments to be left alone, got %s", out)
+	}
+}
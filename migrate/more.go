@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// hugoMore is Hugo's summary divider
+const hugoMore = "<!--more-->"
+
+// nextpage separates the pages of a WordPress multi-page post
+const nextpage = "<!--nextpage-->"
+
+var (
+	// moreRegexp matches WordPress "read more" markers, which may have a custom
+	// link text, and the wrappers the block editor puts around them
+	moreRegexp = regexp.MustCompile(`(?:<!--\s*wp:more\b[^>]*-->\s*)?<!--\s*more\b[^>]*?-->(?:\s*<!--\s*/wp:more\s*-->)?`)
+	// nextpageRegexp matches page breaks, with their block editor wrappers
+	nextpageRegexp = regexp.MustCompile(`(?:<!--\s*wp:nextpage\s*-->\s*)?<!--\s*nextpage\s*-->(?:\s*<!--\s*/wp:nextpage\s*-->)?`)
+)
+
+// convertMore turns the first WordPress "read more" marker into Hugo's
+// summary divider, on a line of its own, and drops any others.
+// Page breaks are normalized to plain <!--nextpage--> markers, see splitPages
+func convertMore(tc TransformContext, content string) string {
+	if tc.Comment != nil {
+		return content
+	}
+	first := true
+	content = moreRegexp.ReplaceAllStringFunc(content, func(string) string {
+		if !first {
+			return ""
+		}
+		first = false
+		return "\n\n" + hugoMore + "\n\n"
+	})
+	return nextpageRegexp.ReplaceAllString(content, nextpage)
+}
+
+// splitPages splits content at its <!--nextpage--> markers. Empty pages are
+// dropped, and there is always at least one page
+func splitPages(content string) []string {
+	var pages []string
+	for _, page := range strings.Split(content, nextpage) {
+		if len(strings.TrimSpace(page)) > 0 {
+			pages = append(pages, strings.TrimSpace(page))
+		}
+	}
+	if len(pages) == 0 {
+		return []string{content}
+	}
+	return pages
+}
@@ -0,0 +1,159 @@
+package migrate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Permalinks maps post types to WordPress-style permalink patterns, like
+// "%year%/%monthnum%/%day%/%postname%". The same pattern gives the URL of an
+// item, and the directory of its page bundle within its section.
+// Post types without a pattern use DefaultPermalink, under a directory named
+// after the post type, like WordPress does for custom post types. Nil
+// Permalinks are the DefaultPermalinks
+type Permalinks map[string]string
+
+// DefaultPermalink is the pattern for post types without one, after the
+// post type
+const DefaultPermalink = "%postname%"
+
+// DefaultPermalinks lays out posts by date, like the usual WordPress setting,
+// and pages following their hierarchy
+func DefaultPermalinks() Permalinks {
+	return Permalinks{
+		"post": "%year%/%monthnum%/%day%/%postname%",
+		"page": "%pagename%",
+	}
+}
+
+// permalinkToken matches the %tokens% in a pattern
+var permalinkToken = regexp.MustCompile(`%[a-z_]+%`)
+
+// authorToken is the author of an item, see Layout.Authors. Permalinks alone
+// expand it as the login name
+const authorToken = "%author%"
+
+// pagenameToken is the slug of a page preceded by those of its ancestors,
+// like parent/child. It is expanded by Layout; Permalinks alone don't know
+// the ancestors and expand it as %postname%
+const pagenameToken = "%pagename%"
+
+// categoryToken is the category of a post preceded by its ancestors, like
+// parent/child. It is expanded by Layout; Permalinks alone don't know the
+// ancestors and expand it as the nicename of the first category
+const categoryToken = "%category%"
+
+// permalinkTokens are the tokens a pattern may use, and their expansion for
+// an item and its date
+var permalinkTokens = map[string]func(i Item, date time.Time) string{
+	"%year%":     func(_ Item, d time.Time) string { return dateToken(d, "2006") },
+	"%monthnum%": func(_ Item, d time.Time) string { return dateToken(d, "01") },
+	"%day%":      func(_ Item, d time.Time) string { return dateToken(d, "02") },
+	"%hour%":     func(_ Item, d time.Time) string { return dateToken(d, "15") },
+	"%minute%":   func(_ Item, d time.Time) string { return dateToken(d, "04") },
+	"%second%":   func(_ Item, d time.Time) string { return dateToken(d, "05") },
+	"%postname%": func(i Item, _ time.Time) string { return i.Slug },
+	"%post_id%":  func(i Item, _ time.Time) string { return strconv.Itoa(i.ID) },
+}
+
+// firstCategory is the nicename of the item's first category
+func firstCategory(i Item) string {
+	for _, ct := range i.Categories {
+		if ct.Domain == "category" {
+			return ct.NiceName
+		}
+	}
+	return "uncategorized" // as WordPress does
+}
+
+func dateToken(d time.Time, layout string) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(layout)
+}
+
+// Validate checks the patterns only use known tokens, and identify each item
+// with its %postname% or %post_id%
+func (p Permalinks) Validate() error {
+	types := make([]string, 0, len(p))
+	for postType := range p {
+		types = append(types, postType)
+	}
+	sort.Strings(types)
+	for _, postType := range types {
+		pattern := p[postType]
+		for _, token := range permalinkToken.FindAllString(pattern, -1) {
+			if _, found := permalinkTokens[token]; !found && token != pagenameToken && token != authorToken &&
+				token != categoryToken {
+				return fmt.Errorf("permalink for %s: unknown token %s", postType, token)
+			}
+		}
+		if !strings.Contains(pattern, "%postname%") && !strings.Contains(pattern, "%post_id%") &&
+			!strings.Contains(pattern, pagenameToken) {
+			return fmt.Errorf("permalink for %s: should contain %%postname%%, %%pagename%% or %%post_id%%",
+				postType)
+		}
+	}
+	return nil
+}
+
+// Path expands the permalink pattern for an item, giving a slash-separated
+// path without leading or trailing slashes.
+// Dates are in the site's local time, like in WordPress. Items without a
+// date, like drafts, get their date segments dropped
+func (p Permalinks) Path(i Item) string {
+	return p.path(i, i.Slug, i.Author, firstCategory(i))
+}
+
+// path expands the pattern for an item, given its %pagename%, %author% and
+// %category%
+func (p Permalinks) path(i Item, pagename, author, category string) string {
+	if p == nil {
+		p = DefaultPermalinks()
+	}
+	pattern, found := p[i.PostType]
+	if !found {
+		pattern = i.PostType + "/" + DefaultPermalink
+	}
+	date, err := time.Parse(WPDateFormat, i.PostDate)
+	if err != nil {
+		date = time.Time{}
+	}
+	expanded := permalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token {
+		case pagenameToken:
+			return pagename
+		case authorToken:
+			return author
+		case categoryToken:
+			return category
+		}
+		expand, found := permalinkTokens[token]
+		if !found {
+			return token
+		}
+		return expand(i, date)
+	})
+	return strings.Trim(path.Clean("/"+expanded), "/")
+}
+
+// has tells if there is a pattern for the post type, rather than the
+// DefaultPermalink
+func (p Permalinks) has(postType string) bool {
+	if p == nil {
+		p = DefaultPermalinks()
+	}
+	_, found := p[postType]
+	return found
+}
+
+// URL is the site-relative URL of an item, with leading and trailing slashes
+func (p Permalinks) URL(i Item) string {
+	return "/" + p.Path(i) + "/"
+}
@@ -0,0 +1,272 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxPHPDepth limits the nesting of the arrays and objects UnserializePHP
+// decodes
+const maxPHPDepth = 64
+
+// ErrNotPHPSerialized is returned by UnserializePHP for text that doesn't
+// look like a PHP serialized value
+var ErrNotPHPSerialized = errors.New("not a PHP serialized value")
+
+// UnserializePHP decodes a value encoded by PHP's serialize(), as found in
+// many postmeta, into nil, bool, int64, float64, string, []interface{} or
+// map[string]interface{}. Arrays with the keys 0, 1, 2... in order become
+// slices, other arrays and objects become maps, with integer keys as
+// strings. Malformed and truncated values, references and custom
+// serializations are errors
+func UnserializePHP(s string) (interface{}, error) {
+	if !LooksPHPSerialized(s) {
+		return nil, ErrNotPHPSerialized
+	}
+	d := phpDecoder{s: s}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(s) {
+		return nil, d.errorf("unexpected data after the value")
+	}
+	return v, nil
+}
+
+// LooksPHPSerialized tells if a text could be a PHP serialized value, as
+// WordPress' is_serialized() does
+func LooksPHPSerialized(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "N;" {
+		return true
+	}
+	if len(s) < 4 || s[1] != ':' {
+		return false
+	}
+	switch s[0] {
+	case 'a', 'O':
+		return strings.HasSuffix(s, "}")
+	case 's':
+		return strings.HasSuffix(s, `";`)
+	case 'b', 'i', 'd':
+		return strings.HasSuffix(s, ";")
+	}
+	return false
+}
+
+// DecodeMeta decodes a postmeta value if it is PHP serialized, and returns it
+// as it is otherwise
+func DecodeMeta(value string) interface{} {
+	if !LooksPHPSerialized(value) {
+		return value
+	}
+	v, err := UnserializePHP(strings.TrimSpace(value))
+	if err != nil {
+		return value
+	}
+	return v
+}
+
+// phpDecoder reads a serialized value from position pos
+type phpDecoder struct {
+	s   string
+	pos int
+}
+
+func (d *phpDecoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("php unserialize at offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+// expect consumes the given text
+func (d *phpDecoder) expect(text string) error {
+	if !strings.HasPrefix(d.s[d.pos:], text) {
+		return d.errorf("expected %q", text)
+	}
+	d.pos += len(text)
+	return nil
+}
+
+// until consumes the text up to the delimiter, and the delimiter
+func (d *phpDecoder) until(delim byte) (string, error) {
+	end := strings.IndexByte(d.s[d.pos:], delim)
+	if end < 0 {
+		return "", d.errorf("expected %q", delim)
+	}
+	text := d.s[d.pos : d.pos+end]
+	d.pos += end + 1
+	return text, nil
+}
+
+// length reads a non-negative length, followed by a colon, at most max
+func (d *phpDecoder) length(max int) (int, error) {
+	text, err := d.until(':')
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 0 || n > max {
+		return 0, d.errorf("bad length %q", text)
+	}
+	return n, nil
+}
+
+// str reads the "text" of a string, given its length in bytes
+func (d *phpDecoder) str(n int) (string, error) {
+	err := d.expect(`"`)
+	if err != nil {
+		return "", err
+	}
+	if len(d.s)-d.pos < n+1 || d.s[d.pos+n] != '"' {
+		return "", d.errorf("string shorter than its length %d", n)
+	}
+	text := d.s[d.pos : d.pos+n]
+	d.pos += n + 1
+	return text, nil
+}
+
+func (d *phpDecoder) value(depth int) (interface{}, error) {
+	if depth > maxPHPDepth {
+		return nil, d.errorf("nested too deep")
+	}
+	if len(d.s)-d.pos < 2 {
+		return nil, d.errorf("truncated value")
+	}
+	kind := d.s[d.pos]
+	if kind == 'N' {
+		return nil, d.expect("N;")
+	}
+	err := d.expect(string(kind) + ":")
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case 'b':
+		text, err := d.until(';')
+		if err != nil {
+			return nil, err
+		}
+		if text != "0" && text != "1" {
+			return nil, d.errorf("bad boolean %q", text)
+		}
+		return text == "1", nil
+	case 'i':
+		text, err := d.until(';')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, d.errorf("bad integer %q", text)
+		}
+		return n, nil
+	case 'd':
+		text, err := d.until(';')
+		if err != nil {
+			return nil, err
+		}
+		switch text {
+		case "INF":
+			return math.Inf(1), nil
+		case "-INF":
+			return math.Inf(-1), nil
+		case "NAN":
+			return math.NaN(), nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, d.errorf("bad float %q", text)
+		}
+		return f, nil
+	case 's':
+		n, err := d.length(len(d.s) - d.pos)
+		if err != nil {
+			return nil, err
+		}
+		text, err := d.str(n)
+		if err != nil {
+			return nil, err
+		}
+		return text, d.expect(";")
+	case 'a':
+		return d.array(depth, false)
+	case 'O':
+		n, err := d.length(len(d.s) - d.pos)
+		if err != nil {
+			return nil, err
+		}
+		_, err = d.str(n) // the class name
+		if err != nil {
+			return nil, err
+		}
+		err = d.expect(":")
+		if err != nil {
+			return nil, err
+		}
+		return d.array(depth, true)
+	}
+	return nil, d.errorf("unsupported type %q", kind)
+}
+
+// array reads the number of elements and the {key;value...} of an array or
+// object
+func (d *phpDecoder) array(depth int, object bool) (interface{}, error) {
+	// every element takes at least 6 bytes, as in i:0;N;
+	n, err := d.length((len(d.s) - d.pos) / 6)
+	if err != nil {
+		return nil, err
+	}
+	err = d.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, n)
+	values := make(map[string]interface{}, n)
+	isList := !object
+	for i := 0; i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		switch k := key.(type) {
+		case int64:
+			name = strconv.FormatInt(k, 10)
+			isList = isList && k == int64(i)
+		case string:
+			// private and protected properties are prefixed by \0class\0
+			if object && strings.HasPrefix(k, "\x00") {
+				if end := strings.IndexByte(k[1:], 0); end >= 0 {
+					k = k[end+2:]
+				}
+			}
+			name = k
+			isList = false
+		default:
+			return nil, d.errorf("bad key type %T", key)
+		}
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := values[name]; !dup {
+			keys = append(keys, name)
+		}
+		values[name] = value
+	}
+	err = d.expect("}")
+	if err != nil {
+		return nil, err
+	}
+	if isList && len(keys) == n {
+		list := make([]interface{}, n)
+		for i, key := range keys {
+			list[i] = values[key]
+		}
+		return list, nil
+	}
+	return values, nil
+}
@@ -0,0 +1,219 @@
+package migrate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TransformContext is what a Transformer knows about the text it is given
+type TransformContext struct {
+	Item    *Item      // the post/page being exported
+	Site    *Site      // the site being migrated
+	Comment *Comment   // the comment being transformed, nil for item content
+	Links   *LinkIndex // the exported items, may be nil
+	// Media collects the media files copied into the item's page bundle,
+	// nil if media are not copied
+	Media *BundleMedia
+	// Originals are the upload paths of the attachments, nil if not known
+	Originals map[string]bool
+	// Attachments are the attachments in the export, by ID
+	Attachments Attachments
+}
+
+// Transformer rewrites the content of an item or a comment
+type Transformer func(tc TransformContext, content string) string
+
+// Step is a named Transformer in a Pipeline
+type Step struct {
+	Name      string
+	Transform Transformer
+}
+
+// Pipeline is an ordered chain of transformation steps. The empty pipeline
+// leaves content untouched
+type Pipeline []Step
+
+// Apply runs the content through the steps, in order
+func (p Pipeline) Apply(tc TransformContext, content string) string {
+	for _, step := range p {
+		content = step.Transform(tc, content)
+	}
+	return content
+}
+
+// Names lists the names of the steps, in order
+func (p Pipeline) Names() []string {
+	names := make([]string, len(p))
+	for i, step := range p {
+		names[i] = step.Name
+	}
+	return names
+}
+
+// Without returns a copy of the pipeline with the named steps left out
+func (p Pipeline) Without(names ...string) Pipeline {
+	var out Pipeline
+	for _, step := range p {
+		if !contains(names, step.Name) {
+			out = append(out, step)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultSteps are the names of the steps in the default pipeline
+var DefaultSteps = []string{"more", "embeds", "linkify", "rewrites", "original-images", "bundle-media", "resolve-links", "self-links", "gallery", "figures", "emoticons"}
+
+var (
+	registryMu   sync.RWMutex
+	transformers = map[string]Transformer{
+		"more":            convertMore,
+		"embeds":          convertEmbeds,
+		"linkify":         linkifyComment,
+		"original-images": originalImages,
+		"bundle-media":    bundleMediaRefs,
+		"rewrites":        applyRewrites,
+		"resolve-links":   resolveLinks,
+		"self-links":      rewriteSelfLinks,
+		"gallery":         renderGalleries,
+		"figures":         convertFigures,
+		"emoticons":       replaceEmoticons,
+	}
+)
+
+// RegisterTransformer makes a Transformer available to NewPipeline by name.
+// Registering an existing name replaces its Transformer
+func RegisterTransformer(name string, t Transformer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	transformers[name] = t
+}
+
+// RegisteredTransformers lists the names of the known transformers, sorted
+func RegisteredTransformers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(transformers))
+	for name := range transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPipeline builds a pipeline from registered transformers, in the order
+// given
+func NewPipeline(names ...string) (Pipeline, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p := make(Pipeline, 0, len(names))
+	for _, name := range names {
+		t, found := transformers[name]
+		if !found {
+			return nil, fmt.Errorf("unknown transformer %q", name)
+		}
+		p = append(p, Step{Name: name, Transform: t})
+	}
+	return p, nil
+}
+
+// DefaultPipeline builds the pipeline with the DefaultSteps
+func DefaultPipeline() Pipeline {
+	p, err := NewPipeline(DefaultSteps...)
+	if err != nil {
+		panic(err) // the default steps are always registered
+	}
+	return p
+}
+
+// linkifyComment makes free urls in comments into links. Posts were written
+// with an editor, and are left alone
+func linkifyComment(tc TransformContext, content string) string {
+	if tc.Comment == nil {
+		return content
+	}
+	linkified, err := LinkifyText(content)
+	if err != nil {
+		return content
+	}
+	return linkified
+}
+
+// applyRewrites applies the site's URL rewrite rules. Earlier rules take
+// precedence
+func applyRewrites(tc TransformContext, content string) string {
+	if tc.Site == nil || len(tc.Site.Rewrites) == 0 {
+		return content
+	}
+	oldnew := make([]string, 0, 2*len(tc.Site.Rewrites))
+	for _, rw := range tc.Site.Rewrites {
+		oldnew = append(oldnew, rw.From, rw.To)
+	}
+	return strings.NewReplacer(oldnew...).Replace(content)
+}
+
+// rewriteSelfLinks makes references to the site portable: media are pointed
+// to the MediaPath, and category and tag archives to Hugo's taxonomy pages
+func rewriteSelfLinks(tc TransformContext, content string) string {
+	if tc.Site == nil {
+		return content
+	}
+	return tc.Site.linkReplacer().Replace(content)
+}
+
+// replaceEmoticons substitutes emoticon shortcodes for Unicode emoticons
+func replaceEmoticons(tc TransformContext, content string) string {
+	emoticons := DefaultEmoticons
+	if tc.Site != nil && tc.Site.Emoticons != nil {
+		emoticons = tc.Site.Emoticons
+	}
+	return emoticonReplacer(emoticons).Replace(content)
+}
+
+// emoticonReplacers caches the replacers of emoticonReplacer by the address
+// of their map, which they keep alive so it is not reused
+var emoticonReplacers sync.Map
+
+type cachedReplacer struct {
+	emoticons map[string]string
+	replacer  *strings.Replacer
+}
+
+// emoticonReplacer is the replacer for a map of emoticons, built once per
+// map. The map must not change once used
+func emoticonReplacer(emoticons map[string]string) *strings.Replacer {
+	key := reflect.ValueOf(emoticons).Pointer()
+	if cached, ok := emoticonReplacers.Load(key); ok {
+		return cached.(cachedReplacer).replacer
+	}
+	codes := make([]string, 0, len(emoticons))
+	for code := range emoticons {
+		codes = append(codes, code)
+	}
+	// longer codes first, so the result doesn't depend on map order
+	sort.Slice(codes, func(i, j int) bool {
+		if len(codes[i]) != len(codes[j]) {
+			return len(codes[i]) > len(codes[j])
+		}
+		return codes[i] < codes[j]
+	})
+	oldnew := make([]string, 0, 2*len(codes))
+	for _, code := range codes {
+		oldnew = append(oldnew, code, emoticons[code])
+	}
+	replacer := strings.NewReplacer(oldnew...)
+	emoticonReplacers.Store(key, cachedReplacer{emoticons: emoticons, replacer: replacer})
+	return replacer
+}
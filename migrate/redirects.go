@@ -0,0 +1,210 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redirect is a permanent redirect from a path in the old site to a path in
+// the new one. The From path may have a query, like /?p=123.
+// A Prefix redirect applies to every path starting with From, which is
+// replaced by To
+type Redirect struct {
+	From   string
+	To     string
+	Prefix bool
+}
+
+// Redirect map formats for WriteRedirects
+const (
+	RedirectsNginx   = "nginx"
+	RedirectsApache  = "apache"
+	RedirectsNetlify = "netlify"
+	RedirectsCaddy   = "caddy"
+)
+
+// RedirectFormats lists the supported redirect map formats
+func RedirectFormats() []string {
+	return []string{RedirectsNginx, RedirectsApache, RedirectsNetlify, RedirectsCaddy}
+}
+
+// RedirectsFileName is the name of the file Export writes a redirect map to
+func RedirectsFileName(format string) string {
+	switch format {
+	case RedirectsNginx:
+		return "redirects.nginx.conf"
+	case RedirectsApache:
+		return "redirects.htaccess"
+	case RedirectsNetlify:
+		return "_redirects"
+	case RedirectsCaddy:
+		return "redirects.caddy"
+	}
+	return ""
+}
+
+// itemRedirects lists the redirects from the old URLs of an item to its new
+// URL
+func (s Site) itemRedirects(i Item, pageURL string) []Redirect {
+	to := normalizePath(pageURL) + "/"
+	var redirects []Redirect
+	for _, alias := range s.Aliases(i, pageURL) {
+		redirects = append(redirects, Redirect{From: alias, To: to})
+	}
+	return redirects
+}
+
+// termRedirects send the old category and tag archives to the term pages.
+// Subcategories are redirected from the path with their ancestors, which
+// WordPress served, and from their slug alone, which it redirected there
+func (tx Taxonomies) termRedirects() []Redirect {
+	var redirects []Redirect
+	for _, taxonomy := range []struct {
+		name  string
+		terms Terms
+	}{{TaxonomyCategories, tx.Categories}, {TaxonomyTags, tx.Tags}} {
+		for _, term := range taxonomy.terms.Sorted() {
+			to := "/" + TermDir(taxonomy.name, term) + "/"
+			redirects = append(redirects, Redirect{From: taxonomy.terms.wpPath(taxonomy.name, term), To: to})
+			if len(term.Parent) > 0 {
+				redirects = append(redirects, Redirect{From: "/category/" + term.Slug + "/", To: to})
+			}
+		}
+	}
+	return redirects
+}
+
+// mediaRedirects send the WordPress upload paths to the MediaPath
+func (s Site) mediaRedirects() []Redirect {
+	to := normalizePath(s.MediaPath) + "/"
+	if to == "//" {
+		to = "/"
+	}
+	return []Redirect{
+		{From: "/wp-content/uploads/", To: to, Prefix: true},
+		{From: "/files/", To: to, Prefix: true}, // wordpress.com sites
+	}
+}
+
+// uniqueRedirects drops repeated redirects, and those from a path to itself.
+// The first redirect for a path wins, and the result is sorted by path
+func uniqueRedirects(redirects []Redirect) []Redirect {
+	seen := make(map[string]bool)
+	var out []Redirect
+	for _, r := range redirects {
+		if seen[r.From] || r.From == r.To {
+			continue
+		}
+		seen[r.From] = true
+		out = append(out, r)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].From < out[j].From })
+	return out
+}
+
+// WriteRedirects writes a redirect map in one of the RedirectFormats
+func WriteRedirects(w io.Writer, format string, redirects []Redirect) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case RedirectsNginx:
+		writeNginxRedirects(bw, redirects)
+	case RedirectsApache:
+		writeApacheRedirects(bw, redirects)
+	case RedirectsNetlify:
+		writeNetlifyRedirects(bw, redirects)
+	case RedirectsCaddy:
+		writeCaddyRedirects(bw, redirects)
+	default:
+		return fmt.Errorf("unknown redirects format %q, want one of %s",
+			format, strings.Join(RedirectFormats(), ", "))
+	}
+	return bw.Flush()
+}
+
+// splitQuery separates the path and the query of a Redirect's From
+func splitQuery(from string) (path, query string) {
+	n := strings.Index(from, "?")
+	if n < 0 {
+		return from, ""
+	}
+	return from[:n], from[n+1:]
+}
+
+// writeNginxRedirects writes a map on $request_uri, which includes the query
+func writeNginxRedirects(w io.Writer, redirects []Redirect) {
+	fmt.Fprintln(w, "# Include in the http block, and in the server block add:")
+	fmt.Fprintln(w, "#   if ($wp_redirect) { return 301 $wp_redirect; }")
+	fmt.Fprintln(w, "map $request_uri $wp_redirect {")
+	for _, r := range redirects {
+		if r.Prefix {
+			fmt.Fprintf(w, "    \"~^%s(?<wp_rest>.*)$\" \"%s$wp_rest\";\n", regexp.QuoteMeta(r.From), r.To)
+			continue
+		}
+		fmt.Fprintf(w, "    %q %q;\n", r.From, r.To)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeApacheRedirects writes mod_rewrite rules for an .htaccess file.
+// RewriteRule matches the decoded path, and the escapes in the targets are
+// kept as they are with NE
+func writeApacheRedirects(w io.Writer, redirects []Redirect) {
+	fmt.Fprintln(w, "RewriteEngine On")
+	for _, r := range redirects {
+		path, query := splitQuery(r.From)
+		if decoded, err := url.PathUnescape(path); err == nil {
+			path = decoded
+		}
+		pattern := strings.ReplaceAll(regexp.QuoteMeta(strings.TrimPrefix(path, "/")), " ", `\x20`)
+		// %N would be a back-reference to a RewriteCond
+		to := strings.ReplaceAll(r.To, "%", `\%`)
+		if r.Prefix {
+			fmt.Fprintf(w, "RewriteRule ^%s(.*)$ %s$1 [R=301,NE,L]\n", pattern, to)
+			continue
+		}
+		if len(query) > 0 {
+			fmt.Fprintf(w, "RewriteCond %%{QUERY_STRING} ^%s$\n", regexp.QuoteMeta(query))
+		}
+		// the trailing ? drops the old query
+		fmt.Fprintf(w, "RewriteRule ^%s$ %s? [R=301,NE,L]\n", pattern, to)
+	}
+}
+
+// writeNetlifyRedirects writes a _redirects file, for Netlify and the hosts
+// that copied its format
+func writeNetlifyRedirects(w io.Writer, redirects []Redirect) {
+	for _, r := range redirects {
+		path, query := splitQuery(r.From)
+		switch {
+		case r.Prefix:
+			fmt.Fprintf(w, "%s*  %s:splat  301\n", path, r.To)
+		case len(query) > 0:
+			fmt.Fprintf(w, "%s  %s  %s  301\n", path, strings.ReplaceAll(query, "&", " "), r.To)
+		default:
+			fmt.Fprintf(w, "%s  %s  301\n", path, r.To)
+		}
+	}
+}
+
+// writeCaddyRedirects writes directives to import into a Caddyfile site block
+func writeCaddyRedirects(w io.Writer, redirects []Redirect) {
+	for n, r := range redirects {
+		path, query := splitQuery(r.From)
+		switch {
+		case r.Prefix:
+			fmt.Fprintf(w, "@wp_redirect_%d path_regexp wp_redirect_%d ^%s(.*)$\n",
+				n, n, regexp.QuoteMeta(path))
+			fmt.Fprintf(w, "redir @wp_redirect_%d %s{re.wp_redirect_%d.1} 301\n", n, r.To, n)
+		case len(query) > 0:
+			fmt.Fprintf(w, "@wp_redirect_%d {\n\tpath %s\n\tquery %s\n}\n", n, path, query)
+			fmt.Fprintf(w, "redir @wp_redirect_%d %s 301\n", n, r.To)
+		default:
+			fmt.Fprintf(w, "redir %s %s 301\n", path, r.To)
+		}
+	}
+}
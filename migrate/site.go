@@ -0,0 +1,70 @@
+package migrate
+
+import "strings"
+
+// Site describes the WordPress site being migrated
+type Site struct {
+	Domains      []string          `yaml:"domains"`      // host names the site was served from
+	MediaDomains []string          `yaml:"mediaDomains"` // host names its media were served from
+	MediaPath    string            `yaml:"mediaPath"`    // where media are served in the new site
+	Emoticons    map[string]string `yaml:"emoticons"`    // DefaultEmoticons if nil
+	Rewrites     []Rewrite         `yaml:"rewrites"`     // for the "rewrites" transformer
+	Timezone     string            `yaml:"timezone"`     // IANA name for the dates, UTC if empty
+	Embeds       map[string]string `yaml:"embeds"`       // oEmbed provider -> shortcode, DefaultEmbeds if nil
+	Schemes      []string          `yaml:"schemes"`      // to download media with, in order, DefaultSchemes if empty
+	// ImageDimensions gives images pointed at their originals the width and
+	// height of the resized image they showed
+	ImageDimensions bool `yaml:"imageDimensions"`
+}
+
+// Rewrite is a URL rewrite rule: text starting with From is changed to start
+// with To instead
+type Rewrite struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// DefaultSite is the site this tool was first written for
+func DefaultSite() Site {
+	return Site{
+		Domains:      []string{"plazamoyua.com", "plazamoyua.wordpress.com"},
+		MediaDomains: []string{"plazamoyua.files.wordpress.com"},
+		MediaPath:    "/media",
+	}
+}
+
+// DefaultEmoticons maps the WordPress emoticon shortcodes to Unicode
+var DefaultEmoticons = map[string]string{
+	":cry:":     "😥",
+	":shock:":   "😯",
+	":grin:":    "😀",
+	":razz:":    "😛",
+	":P":        "😛",
+	":)":        "🙂",
+	";)":        "😉",
+	":wink:":    "😉",
+	":lol:":     "😆",
+	":arrow:":   "➡",
+	":twisted:": "😈",
+	":idea:":    "💡",
+	":evil:":    "👿",
+	":oops:":    "😳",
+	":roll:":    "🙄",
+}
+
+// linkReplacer rewrites absolute urls into the site as site-relative ones
+func (s Site) linkReplacer() *strings.Replacer {
+	var oldnew []string
+	for _, scheme := range []string{"http://", "https://"} {
+		for _, domain := range s.MediaDomains {
+			oldnew = append(oldnew, scheme+domain, s.MediaPath)
+		}
+		for _, domain := range s.Domains {
+			oldnew = append(oldnew,
+				scheme+domain+"/tag/", "/tags/",
+				scheme+domain+"/category/", "/categories/",
+				scheme+domain+"/", "/")
+		}
+	}
+	return strings.NewReplacer(oldnew...)
+}
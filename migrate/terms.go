@@ -0,0 +1,169 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	textTpl "text/template"
+)
+
+// Hugo taxonomies for the WordPress categories and tags
+const (
+	TaxonomyCategories = "categories"
+	TaxonomyTags       = "tags"
+)
+
+// Term is a category or tag. Items refer to it by its slug, which is kept
+// for its URL, and its term page shows the display name
+type Term struct {
+	ID          int // 0 for the terms only found in items
+	Slug        string
+	Name        string
+	Description string
+	Parent      string // slug of the parent term, categories only
+}
+
+// Terms are the terms of a taxonomy by slug
+type Terms map[string]Term
+
+// Taxonomies has the categories and tags of a site
+type Taxonomies struct {
+	Categories Terms
+	Tags       Terms
+}
+
+// NewTaxonomies collects the terms defined in the channel of an export, and
+// those only found in its items
+func NewTaxonomies(doc RSS) Taxonomies {
+	tx := Taxonomies{Categories: make(Terms), Tags: make(Terms)}
+	for _, c := range doc.Categories {
+		tx.Categories[c.Slug] = Term{ID: c.ID, Slug: c.Slug, Name: c.Name, Description: c.Description, Parent: c.Parent}
+	}
+	for _, t := range doc.Tags {
+		tx.Tags[t.Slug] = Term{ID: t.ID, Slug: t.Slug, Name: t.Name, Description: t.Description}
+	}
+	for _, it := range doc.Items {
+		for _, ct := range it.Categories {
+			terms := tx.terms(ct.Domain)
+			if terms == nil || len(ct.NiceName) == 0 {
+				continue
+			}
+			if _, found := terms[ct.NiceName]; !found {
+				terms[ct.NiceName] = Term{Slug: ct.NiceName, Name: strings.TrimSpace(ct.Data)}
+			}
+		}
+	}
+	return tx
+}
+
+// terms are the Terms for the domain of an item's category element
+func (tx Taxonomies) terms(domain string) Terms {
+	switch domain {
+	case "category":
+		return tx.Categories
+	case "post_tag":
+		return tx.Tags
+	}
+	return nil
+}
+
+// Used are the terms the items are filed under, with the ancestors of the
+// categories, sorted by slug
+func (tx Taxonomies) Used(items []Item) Taxonomies {
+	used := Taxonomies{Categories: make(Terms), Tags: make(Terms)}
+	for _, it := range items {
+		for _, ct := range it.Categories {
+			terms, usedTerms := tx.terms(ct.Domain), used.terms(ct.Domain)
+			if terms == nil {
+				continue
+			}
+			// walk up the parents, without looping on broken exports
+			for slug := ct.NiceName; len(slug) > 0; slug = terms[slug].Parent {
+				term, found := terms[slug]
+				if _, seen := usedTerms[slug]; !found || seen {
+					break
+				}
+				usedTerms[slug] = term
+			}
+		}
+	}
+	return used
+}
+
+// Sorted lists the terms by slug
+func (terms Terms) Sorted() []Term {
+	list := make([]Term, 0, len(terms))
+	for _, term := range terms {
+		list = append(list, term)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Slug < list[j].Slug })
+	return list
+}
+
+// wpPath is where WordPress served the archive of a category or tag.
+// Category archives include the slugs of their ancestors
+func (terms Terms) wpPath(taxonomy string, term Term) string {
+	if taxonomy == TaxonomyTags {
+		return "/tag/" + term.Slug + "/"
+	}
+	return "/category/" + terms.path(term) + "/"
+}
+
+// path is the slug of a category preceded by those of its ancestors, like
+// parent/child
+func (terms Terms) path(term Term) string {
+	slugs := []string{term.Slug}
+	seen := map[string]bool{term.Slug: true}
+	for parent := term.Parent; len(parent) > 0 && !seen[parent]; parent = terms[parent].Parent {
+		seen[parent] = true
+		slugs = append([]string{parent}, slugs...)
+	}
+	return strings.Join(slugs, "/")
+}
+
+// TermDir is the directory of a term page, relative to the output dir
+func TermDir(taxonomy string, term Term) string {
+	return taxonomy + "/" + term.Slug
+}
+
+// WriteTermPage writes the _index.md of a term page, with its display name,
+// description and parent, and aliases for its old archive URL
+func (terms Terms) WriteTermPage(writer io.Writer, taxonomy string, term Term) error {
+	data := struct {
+		Title, Description, Parent, AliasesLine string
+	}{
+		Title:  strconv.Quote(term.Name),
+		Parent: term.Parent,
+	}
+	if len(term.Name) == 0 {
+		data.Title = strconv.Quote(term.Slug)
+	}
+	if len(term.Description) > 0 {
+		data.Description = strconv.Quote(term.Description)
+	}
+	aliases := []string{terms.wpPath(taxonomy, term)}
+	if len(term.Parent) > 0 {
+		// what the self-links transformer makes of links to the old URL
+		aliases = append(aliases, "/"+taxonomy+strings.TrimPrefix(aliases[0], "/category"))
+	}
+	data.AliasesLine = fmt.Sprintf(`aliases: ["%s"]`, strings.Join(aliases, `", "`))
+	err := termTpl.Execute(writer, data)
+	if err != nil {
+		return fmt.Errorf("could not write term %s: %v", term.Slug, err)
+	}
+	return nil
+}
+
+var termTpl = textTpl.Must(textTpl.New("term").Parse(`---
+title: {{.Title}}
+{{- with .Description}}
+description: {{.}}
+{{- end}}
+{{- with .Parent}}
+parent: "{{.}}"
+{{- end}}
+{{.AliasesLine}}
+---
+`))
@@ -0,0 +1,143 @@
+package migrate
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// sizeSuffixRegexp matches the size WordPress adds to the names of the
+	// images it generates, as in photo-300x200.jpg
+	sizeSuffixRegexp = regexp.MustCompile(`-(\d+)x(\d+)(\.[[:alnum:]]+)$`)
+	// mediaTagRegexp matches the tags that refer to images
+	mediaTagRegexp = regexp.MustCompile(`(?i)<(img|a)\s[^>]*>`)
+	// attrRegexp matches an attribute in a tag, with either quote
+	attrRegexp = regexp.MustCompile(`\s([\w-]+)\s*=\s*("([^"]*)"|'([^']*)')`)
+)
+
+// ImageVariant is an image WordPress resized from an original upload
+type ImageVariant struct {
+	Original      string // upload path of the original
+	Width, Height int    // requested size, 0 if not known
+}
+
+// ImageVariant tells if a media URL refers to a resized image, either by a
+// -WxH suffix in the file name or by the w and h parameters of wordpress.com.
+// originals are the upload paths of the attachments; a name with a size
+// suffix that is itself an attachment is not a variant. If originals is nil
+// any name with a size suffix is taken for a variant
+func (s Site) ImageVariant(ref string, originals map[string]bool) (ImageVariant, bool) {
+	uploadPath, ok := s.UploadPath(ref)
+	if !ok {
+		return ImageVariant{}, false
+	}
+	variant := ImageVariant{Original: uploadPath}
+	if u, err := url.Parse(strings.TrimSpace(html.UnescapeString(ref))); err == nil {
+		query := u.Query()
+		variant.Width, _ = strconv.Atoi(query.Get("w"))
+		variant.Height, _ = strconv.Atoi(query.Get("h"))
+		if query.Has("resize") {
+			size := strings.Split(query.Get("resize"), ",")
+			if len(size) == 2 {
+				variant.Width, _ = strconv.Atoi(size[0])
+				variant.Height, _ = strconv.Atoi(size[1])
+			}
+		}
+	}
+	if m := sizeSuffixRegexp.FindStringSubmatch(uploadPath); m != nil && !originals[uploadPath] {
+		original := strings.TrimSuffix(uploadPath, m[0]) + m[3]
+		if originals == nil || originals[original] {
+			variant.Original = original
+			variant.Width, _ = strconv.Atoi(m[1])
+			variant.Height, _ = strconv.Atoi(m[2])
+		}
+	}
+	return variant, variant.Original != uploadPath || variant.Width > 0 || variant.Height > 0
+}
+
+// UploadPaths are the upload paths of the attachment files, nil if there
+// are no attachments
+func (a Attachments) UploadPaths(s Site) map[string]bool {
+	if len(a) == 0 {
+		return nil
+	}
+	paths := make(map[string]bool)
+	for _, att := range a {
+		if uploadPath, ok := s.UploadPath(att.AttachmentURL); ok {
+			paths[uploadPath] = true
+		}
+	}
+	return paths
+}
+
+// originalURL points a media URL at the original of a variant, keeping the
+// host, and drops its query
+func originalURL(ref string, variant ImageVariant) string {
+	u, err := url.Parse(strings.TrimSpace(html.UnescapeString(ref)))
+	if err != nil {
+		return ref
+	}
+	u.Path = path.Join(path.Dir(u.Path), path.Base(variant.Original))
+	u.RawQuery = ""
+	return html.EscapeString(u.String())
+}
+
+// originalImages points images and links to resized images at the original
+// uploads, which are all a media export has. The srcset and sizes of the
+// images are dropped, as they list variants too, and with
+// Site.ImageDimensions the requested size becomes the width and height of
+// images that don't have them
+func originalImages(tc TransformContext, content string) string {
+	if tc.Site == nil {
+		return content
+	}
+	return mediaTagRegexp.ReplaceAllStringFunc(content, func(tag string) string {
+		isImg := strings.EqualFold(mediaTagRegexp.FindStringSubmatch(tag)[1], "img")
+		var variant ImageVariant
+		var hasWidth, hasHeight, changed bool
+		tag = attrRegexp.ReplaceAllStringFunc(tag, func(attr string) string {
+			m := attrRegexp.FindStringSubmatch(attr)
+			name := strings.ToLower(m[1])
+			switch {
+			case isImg && (name == "srcset" || name == "sizes"):
+				changed = true
+				return ""
+			case isImg && name == "width":
+				hasWidth = true
+			case isImg && name == "height":
+				hasHeight = true
+			case (isImg && name == "src") || (!isImg && name == "href"):
+				v, ok := tc.Site.ImageVariant(m[3]+m[4], tc.Originals)
+				if !ok {
+					return attr
+				}
+				variant, changed = v, true
+				return fmt.Sprintf(` %s="%s"`, m[1], originalURL(m[3]+m[4], v))
+			}
+			return attr
+		})
+		if !changed || !isImg || !tc.Site.ImageDimensions {
+			return tag
+		}
+		var dims string
+		if !hasWidth && variant.Width > 0 {
+			dims += fmt.Sprintf(` width="%d"`, variant.Width)
+		}
+		if !hasHeight && variant.Height > 0 {
+			dims += fmt.Sprintf(` height="%d"`, variant.Height)
+		}
+		end := len(tag) - 1
+		if strings.HasSuffix(tag, "/>") {
+			end--
+			for end > 0 && tag[end-1] == ' ' {
+				end--
+			}
+		}
+		return tag[:end] + dims + tag[end:]
+	})
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import "time"
+
+// WPDateFormat is the layout of the wp:post_date and wp:post_date_gmt fields
+const WPDateFormat = "2006-01-02 15:04:05"
+
+// Filter selects the items to export. Every criterion that is set must
+// match; within a criterion, matching any of the values is enough.
+// An empty filter lets everything through
+type Filter struct {
+	Types      map[string]bool // post types to export, all if empty
+	SkipTypes  map[string]bool // post types never to export
+	From, To   time.Time       // window on PostDateGMT, To excluded; open if zero
+	Categories map[string]bool // category nicenames
+	Tags       map[string]bool // tag nicenames
+	Authors    map[string]bool // dc:creator logins
+	IDs        map[int]bool    // post IDs
+}
+
+// Match tells whether an item passes the filter
+func (f Filter) Match(it Item) bool {
+	if f.SkipTypes[it.PostType] {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[it.PostType] {
+		return false
+	}
+	if len(f.IDs) > 0 && !f.IDs[it.ID] {
+		return false
+	}
+	if len(f.Authors) > 0 && !f.Authors[it.Author] {
+		return false
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		date, err := time.Parse(WPDateFormat, it.PostDateGMT)
+		if err != nil {
+			// drafts have a zeroed GMT date, the local one may still be good
+			date, err = time.Parse(WPDateFormat, it.PostDate)
+		}
+		if err != nil {
+			return false
+		}
+		if !f.From.IsZero() && date.Before(f.From) {
+			return false
+		}
+		if !f.To.IsZero() && !date.Before(f.To) {
+			return false
+		}
+	}
+	if len(f.Categories) > 0 && !hasTerm(it, "category", f.Categories) {
+		return false
+	}
+	if len(f.Tags) > 0 && !hasTerm(it, "post_tag", f.Tags) {
+		return false
+	}
+	return true
+}
+
+func hasTerm(it Item, domain string, nicenames map[string]bool) bool {
+	for _, ct := range it.Categories {
+		if ct.Domain == domain && nicenames[ct.NiceName] {
+			return true
+		}
+	}
+	return false
+}
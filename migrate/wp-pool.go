@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"sync"
+)
+
+// poolResult is the outcome of one task in runPool, with the log lines it
+// produced held back so they can be printed in task order
+type poolResult struct {
+	index int
+	log   bytes.Buffer
+	err   error
+}
+
+// runPool runs task for every index in [0, n) using up to `workers` concurrent
+// goroutines.
+// Each task gets its own logger. The logs are written to `out` in index order
+// as soon as all the previous tasks are done, so the output is the same as if
+// the tasks had run one after the other. The errors are returned in index
+// order too.
+// Once the context is cancelled no more tasks are started
+func runPool(
+	ctx context.Context, workers, n int, task func(i int, logger *log.Logger) error, out io.Writer,
+) []error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	results := make(chan *poolResult)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				res := &poolResult{index: i}
+				res.err = task(i, log.New(&res.log, "", 0))
+				results <- res
+			}
+		}()
+	}
+	go func() {
+	dispatch:
+		for i := 0; i < n; i++ {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(indexes)
+		wg.Wait()
+		close(results)
+	}()
+
+	var errs []error
+	pending := make(map[int]*poolResult)
+	next := 0
+	for res := range results {
+		pending[res.index] = res
+		for done, found := pending[next]; found; done, found = pending[next] {
+			_, err := out.Write(done.log.Bytes())
+			if err != nil {
+				log.Println("could not write log: ", err)
+			}
+			if done.err != nil {
+				errs = append(errs, done.err)
+			}
+			delete(pending, next)
+			next++
+		}
+	}
+	return errs
+}
@@ -0,0 +1,122 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StatusAction is what the export does with an item in a given WordPress
+// status
+type StatusAction string
+
+const (
+	ActionPublish StatusAction = "publish" // export as a regular page
+	ActionDraft   StatusAction = "draft"   // export with `draft: true`
+	ActionFuture  StatusAction = "future"  // export with a `publishDate`
+	ActionPrivate StatusAction = "private" // export into the private section
+	ActionSkip    StatusAction = "skip"    // do not export
+)
+
+// PrivateSection is the directory under the output dir where items with
+// ActionPrivate are written, away from the public sections
+const PrivateSection = "private"
+
+// StatusPolicy maps WordPress post statuses (publish, future, draft, pending,
+// private, trash ...) to what should be done with them on export
+type StatusPolicy map[string]StatusAction
+
+// DefaultStatusPolicy errs on the side of caution: nothing that was not
+// public in WordPress is published by Hugo with the default settings, but
+// drafts and scheduled posts are kept
+func DefaultStatusPolicy() StatusPolicy {
+	return StatusPolicy{
+		"publish":    ActionPublish,
+		"future":     ActionFuture,
+		"draft":      ActionDraft,
+		"pending":    ActionDraft,
+		"private":    ActionSkip,
+		"auto-draft": ActionSkip,
+		"inherit":    ActionSkip,
+		"trash":      ActionSkip,
+		"trashed":    ActionSkip,
+	}
+}
+
+// Action returns the action for a status. Unknown statuses are skipped
+func (p StatusPolicy) Action(status string) StatusAction {
+	act, found := p[status]
+	if !found {
+		return ActionSkip
+	}
+	return act
+}
+
+// Set changes the action for a status, checking the action name is valid
+func (p StatusPolicy) Set(status, action string) error {
+	act := StatusAction(action)
+	switch act {
+	case ActionPublish, ActionDraft, ActionFuture, ActionPrivate, ActionSkip:
+		p[status] = act
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q for status %q: want one of %s",
+			action, status, strings.Join(StatusActionNames(), ", "))
+	}
+}
+
+// String lists the policy as status=action pairs, sorted by status
+func (p StatusPolicy) String() string {
+	pairs := make([]string, 0, len(p))
+	for status, act := range p {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", status, act))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// StatusActionNames lists the valid actions
+func StatusActionNames() []string {
+	return []string{
+		string(ActionPublish), string(ActionDraft), string(ActionFuture),
+		string(ActionPrivate), string(ActionSkip),
+	}
+}
+
+// unsluggedStatuses are those WordPress gives no post_name until the item is
+// published
+var unsluggedStatuses = []string{"draft", "pending"}
+
+// fillSlugs gives the items WordPress left without a slug one made by
+// fallbackSlug, followed by their ID if an item of the same type has it
+func fillSlugs(items []Item) {
+	taken := make(map[string]bool)
+	for _, it := range items {
+		if len(it.Slug) > 0 {
+			taken[it.PostType+"/"+it.Slug] = true
+		}
+	}
+	for n := range items {
+		it := &items[n]
+		if len(it.Slug) > 0 || !contains(unsluggedStatuses, it.Status) {
+			continue
+		}
+		slug := fallbackSlug(*it)
+		if taken[it.PostType+"/"+slug] {
+			slug = fmt.Sprintf("%s-%d", slug, it.ID)
+		}
+		taken[it.PostType+"/"+slug] = true
+		it.Slug = slug
+	}
+}
+
+// fallbackSlug makes a slug for an item that has none: from its title, or
+// its ID if the title has no letters or digits
+func fallbackSlug(i Item) string {
+	slug := slugify(i.Title)
+	if len(slug) == 0 {
+		return strconv.Itoa(i.ID)
+	}
+	return slug
+}
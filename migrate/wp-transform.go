@@ -0,0 +1,435 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	textTpl "text/template"
+)
+
+// ThreadComments takes a list of comments, sorts through their
+// family tree using the `ParentID` and `ID` attributes, and converts them
+// to a list of threads where each node has its Children threads
+//
+// NOTE: assumes the comments form a tree
+func ThreadComments(comments []Comment) []CommentThread {
+	commentsWithParentID := make(map[int][]Comment)
+	for _, cm := range comments {
+		if cm.Approved != "trash" {
+			commentsWithParentID[cm.ParentID] = append(commentsWithParentID[cm.ParentID], cm)
+		}
+	}
+
+	roots := make([]CommentThread, 0, len(commentsWithParentID[0]))
+	for _, c := range commentsWithParentID[0] {
+		roots = append(roots, threadCommentLevel(c, commentsWithParentID))
+	}
+
+	return roots
+}
+
+func threadCommentLevel(
+	node Comment, commentsWithParentID map[int][]Comment,
+) CommentThread {
+	threads := make([]CommentThread, len(commentsWithParentID[node.ID]))
+	for i, c := range commentsWithParentID[node.ID] {
+		threads[i] = threadCommentLevel(c, commentsWithParentID)
+	}
+	return CommentThread{
+		Comment:  node,
+		Children: threads,
+	}
+}
+
+// LinkifyText finds "free" urls in text (i.e. urls not in an <a href=""> context), and
+// puts them inside an <a>
+//
+// Main link matcher regex inspired by
+// https://stackoverflow.com/questions/26561149/golang-regex-to-find-urls-in-a-string
+func LinkifyText(in string) (string, error) {
+	return freeURLRegexp.ReplaceAllString(in, `$1<a href="$2">$2</a>`), nil
+}
+
+// freeURLRegexp matches urls preceded by whitespace, see LinkifyText
+var freeURLRegexp = regexp.MustCompile(`(\s+)((http|ftp|https)://([\w\-_]+(?:(?:\.[\w\-_]+)+))([\w\-\.,@?^=%&amp;:/~\+#]*[\w\-\@?^=%&amp;/~\+#])?)`)
+
+// ContentRenderer contains methods to transform and render the XML content
+// into other formats.
+// Its methods are safe for concurrent use, provided the Pipeline steps are
+type ContentRenderer struct {
+	Pipeline Pipeline     // transforms post content and comments
+	Site     Site         // the site being migrated
+	Statuses StatusPolicy // decides draft / publishDate front matter
+	Links    *LinkIndex   // to resolve internal links, may be nil
+	Layout   Layout       // gives the url front matter
+	Logger   *log.Logger  // for warnings; the standard logger if nil
+	// Media collects the media to copy into the item's bundle, nil to leave
+	// media where they are
+	Media *BundleMedia
+	// Attachments give the featured images, which are left out if nil
+	Attachments Attachments
+	// Originals are the upload paths of the attachments, to tell resized
+	// images from the originals; nil if not known
+	Originals map[string]bool
+	// Authors give the display names and author pages of the item authors,
+	// which are shown by login if nil
+	Authors Authors
+	// Meta says which postmeta go into the front matter
+	Meta MetaMapping
+	// ExcerptField is the front matter field for the excerpt,
+	// DefaultExcerptField if empty
+	ExcerptField string
+}
+
+func (cr ContentRenderer) logf(format string, args ...interface{}) {
+	if cr.Logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	cr.Logger.Printf(format, args...)
+}
+
+func escapeTitleQuotes(s string) string {
+	r := strings.NewReplacer("\"", "\\\"")
+	return r.Replace(s)
+}
+
+// XML namespaces of the Encodeds of an Item
+const (
+	contentSpace = "http://purl.org/rss/1.0/modules/content/"
+	excerptSpace = "http://wordpress.org/export/1.2/excerpt/"
+)
+
+// DefaultExcerptField is the front matter field for the excerpt of a post
+const DefaultExcerptField = "summary"
+
+// encodedData returns the payload of an item in the given namespace
+func encodedData(i Item, space string) string {
+	var data string
+	for _, enc := range i.Encodeds {
+		if enc.XMLName.Space == space {
+			data = enc.Data
+		}
+	}
+	return data
+}
+
+// pagePart tells which page of a post split at <!--nextpage--> markers is
+// being rendered. The zero pagePart is the whole post
+type pagePart struct {
+	Number, Count int
+}
+
+// ToMarkdown adds a Hugo/jekyll front matter and displays a post/page as
+// markdown
+func (cr ContentRenderer) ToMarkdown(i Item, writer io.Writer) error {
+	return cr.toMarkdown(i, cr.content(i), pagePart{}, writer)
+}
+
+// ToMarkdownPages renders a post split at its <!--nextpage--> markers, as
+// one markdown document per page. See Layout.PartURL for the URLs of the
+// pages after the first
+func (cr ContentRenderer) ToMarkdownPages(i Item) ([][]byte, error) {
+	parts := splitPages(cr.content(i))
+	docs := make([][]byte, len(parts))
+	for n, part := range parts {
+		var buffer bytes.Buffer
+		err := cr.toMarkdown(i, part, pagePart{Number: n + 1, Count: len(parts)}, &buffer)
+		if err != nil {
+			return nil, err
+		}
+		docs[n] = buffer.Bytes()
+	}
+	return docs, nil
+}
+
+// content is the item's content run through the pipeline
+func (cr ContentRenderer) content(i Item) string {
+	tc := TransformContext{Item: &i, Site: &cr.Site, Links: cr.Links, Media: cr.Media,
+		Originals: cr.Originals, Attachments: cr.Attachments}
+	return cr.Pipeline.Apply(tc, encodedData(i, contentSpace))
+}
+
+func (cr ContentRenderer) toMarkdown(i Item, content string, part pagePart, writer io.Writer) error {
+	var (
+		tags       []string
+		categories []string
+	)
+	for _, ct := range i.Categories {
+		switch ct.Domain {
+		case "category":
+			categories = append(categories, ct.NiceName)
+		case "post_tag":
+			tags = append(tags, ct.NiceName)
+		default:
+			continue
+		}
+	}
+
+	var categoriesLine, tagsLine string
+	if len(categories) > 0 {
+		categoriesLine = fmt.Sprintf(`categories: ["%s"]`, strings.Join(categories, "\", \""))
+	}
+	if len(tags) > 0 {
+		tagsLine = fmt.Sprintf(`tags: ["%s"]`, strings.Join(tags, "\", \""))
+	}
+
+	data := struct {
+		Title          string
+		PubDate        string
+		Author         string
+		AuthorsLine    string
+		FeaturedImage  string
+		Images         string
+		MetaLines      string
+		Content        string
+		Slug           string
+		Link           string
+		CategoriesLine string
+		TagsLine       string
+		URL            string
+		AliasesLine    string
+		Weight         int
+		Draft          bool
+		Private        bool
+		PublishDate    string
+		LastMod        string
+		ExcerptField   string
+		Excerpt        string
+		Part           pagePart
+	}{
+		Title:          escapeTitleQuotes(i.Title),
+		PubDate:        isoDate(cr.Site.PostTime(i)),
+		Author:         i.Author,
+		Content:        content,
+		Slug:           i.Slug,
+		Link:           i.Link,
+		CategoriesLine: categoriesLine,
+		TagsLine:       tagsLine,
+		Weight:         i.MenuOrder,
+		ExcerptField:   cr.ExcerptField,
+		Part:           part,
+	}
+	if cr.Authors != nil {
+		data.Author = ""
+		if author, found := cr.Authors[i.Author]; found {
+			data.Author = escapeTitleQuotes(author.Name)
+			data.AuthorsLine = fmt.Sprintf(`%s: ["%s"]`, TaxonomyAuthors, author.Slug)
+		}
+	}
+	if len(data.ExcerptField) == 0 {
+		data.ExcerptField = DefaultExcerptField
+	}
+
+	switch cr.Statuses.Action(i.Status) {
+	case ActionDraft:
+		data.Draft = true
+	case ActionFuture:
+		data.PublishDate = data.PubDate
+	case ActionPrivate:
+		// Hugo keeps private items, and their resources, out of the site
+		data.Private = true
+	}
+	if modified := cr.Site.ModifiedTime(i); modified.After(cr.Site.PostTime(i)) {
+		data.LastMod = isoDate(modified)
+	}
+
+	if part.Number > 1 {
+		// the first page has the aliases and excerpt for the post
+		if !data.Private {
+			data.URL = cr.Layout.PartURL(i, part.Number)
+		}
+		if cr.Media != nil {
+			data.Content = cr.Media.relativeToParent(data.Content)
+		}
+		return markdownTpl.Execute(writer, data)
+	}
+	if !data.Private {
+		data.URL = cr.Layout.URL(i)
+	}
+	if att, found := cr.Attachments.Featured(i); found {
+		data.FeaturedImage = cr.Site.MediaURL(att.AttachmentURL)
+		data.Images = data.FeaturedImage
+		if uploadPath, ok := cr.Site.UploadPath(att.AttachmentURL); ok && cr.Media != nil {
+			data.FeaturedImage = cr.Media.Add(uploadPath)
+			data.Images = data.URL + data.FeaturedImage
+		}
+	} else if id, found := i.Meta("_thumbnail_id"); found && cr.Attachments != nil {
+		cr.logf("featured image %s of item %d is not in the export", id, i.ID)
+	}
+	if excerpt := strings.TrimSpace(encodedData(i, excerptSpace)); len(excerpt) > 0 {
+		data.Excerpt = strconv.Quote(excerpt)
+	}
+	lines, err := metaLines(cr.Meta.FrontMatter(i, data.ExcerptField))
+	if err != nil {
+		return err
+	}
+	data.MetaLines = lines
+
+	if aliases := cr.Site.PathAliases(i, data.URL); len(aliases) > 0 && !data.Private {
+		for n, alias := range aliases {
+			aliases[n] = escapeTitleQuotes(alias)
+		}
+		data.AliasesLine = fmt.Sprintf(`aliases: ["%s"]`, strings.Join(aliases, "\", \""))
+	}
+
+	return markdownTpl.Execute(writer, data)
+}
+
+// markdownTpl lays out the front matter and content of a post/page
+var markdownTpl = textTpl.Must(textTpl.New("markdown").Parse(`
+---
+title: "{{.Title }}"
+{{- with .PubDate}}
+date: "{{.}}"
+{{- end}}
+{{- with .Author}}
+author: "{{.}}"
+{{- end}}
+{{- with .AuthorsLine}}
+{{.}}
+{{- end}}
+original: {{.Link}}
+slug: "{{.Slug}}"
+{{- with .URL}}
+url: "{{.}}"
+{{- end}}
+{{- with .AliasesLine}}
+{{.}}
+{{- end}}
+{{- with .FeaturedImage}}
+featured_image: "{{.}}"
+images: ["{{$.Images}}"]
+{{- end}}
+{{.CategoriesLine}}
+{{.TagsLine}}
+{{- with .Weight}}
+weight: {{.}}
+{{- end}}
+{{- if .Draft}}
+draft: true
+{{- end}}
+{{- with .PublishDate}}
+publishDate: "{{.}}"
+{{- end}}
+{{- with .LastMod}}
+lastmod: "{{.}}"
+{{- end}}
+{{- with .Excerpt}}
+{{$.ExcerptField}}: {{.}}
+{{- end}}
+{{- with .Part.Count}}
+part: {{$.Part.Number}}
+parts: {{.}}
+{{- end}}
+{{- if .Private}}
+build:
+  render: never
+  list: never
+  publishResources: false
+{{- else if gt .Part.Number 1}}
+build:
+  list: never
+{{- end}}
+{{- with .MetaLines}}
+{{.}}
+{{- end}}
+---
+
+{{.Content}}`))
+
+// ThreadToHTML renders a single thread as HTML, starting a new
+// sub ordered-list fore each parent/child generation
+// NOTE: Markdown could accomodate this too, but being whitespace-sensitive,
+// this makes it an inconvenient choice. HTML is the better format for code-gen
+func (cr ContentRenderer) ThreadToHTML(i Item, thread CommentThread) (template.HTML, error) {
+	tc := TransformContext{Item: &i, Site: &cr.Site, Comment: &thread.Comment, Links: cr.Links, Media: cr.Media,
+		Originals: cr.Originals, Attachments: cr.Attachments}
+	thread.Content = template.HTML(cr.Pipeline.Apply(tc, string(thread.Content)))
+	date := cr.Site.CommentTime(thread.Comment)
+	data := struct {
+		*CommentThread
+		DateTime string
+		Date     string
+	}{&thread, isoDate(date), thread.CommentDate}
+	if !date.IsZero() {
+		data.Date = date.Format(commentDateLayout)
+	}
+	buffer := bytes.Buffer{}
+	if len(thread.Children) == 0 {
+		err := threadTpl.Execute(&buffer, data)
+		if err != nil {
+			return "", err
+		}
+		return template.HTML(buffer.String()), nil
+	}
+
+	for _, child := range thread.Children {
+		ht, err := cr.ThreadToHTML(i, child)
+		if err != nil {
+			return "", err
+		}
+		thread.ChildrenHTML = append(thread.ChildrenHTML, ht)
+	}
+	err := threadTpl.Execute(&buffer, data)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(buffer.String()), err
+}
+
+// commentDateLayout is how the date of a comment is shown, in the site's
+// time zone
+const commentDateLayout = "2006-01-02 15:04"
+
+// threadTpl renders a comment, with its children already rendered to HTML
+var threadTpl = template.Must(template.New("thread").Parse(`
+	<li>
+	<div class="comment">
+		<span class="author">{{.AuthorName}}</span>
+		<time class="date"{{with .DateTime}} datetime="{{.}}"{{end}}>{{.Date}}</time>
+		<div>
+			{{.Content}}
+		</div>
+		{{ with .ChildrenHTML}}
+		<div class="children">
+			<ul>
+			{{ range . }}
+				{{ . }}
+			{{ end }}
+			</ul>
+		</div>
+		{{ end }}
+	</div>
+	</li>
+`))
+
+// RenderThreads goes overa all the comment threads of an item and renders them
+// to the appropriate writer/file/buffer
+func (cr ContentRenderer) RenderThreads(writer io.Writer, i Item, comments []CommentThread) error {
+	_, err := writer.Write([]byte("<div class=\"comments\"><ul>\n"))
+	if err != nil {
+		return err
+	}
+	for _, c := range comments {
+		ht, err := cr.ThreadToHTML(i, c)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write([]byte(ht))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = writer.Write([]byte("</ul></div>\n"))
+	if err != nil {
+		return err
+	}
+	return nil
+}
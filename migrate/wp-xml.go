@@ -0,0 +1,145 @@
+package migrate
+
+import (
+	"encoding/xml"
+	"html/template"
+)
+
+// RSS is the top-level XML element in the WordPress export
+type RSS struct {
+	XMLName    xml.Name     `xml:"rss"`
+	Items      []Item       `xml:"channel>item"`
+	Categories []WPCategory `xml:"channel>category"` // space: wp
+	Tags       []WPTag      `xml:"channel>tag"`      // space: wp
+	Authors    []WPAuthor   `xml:"channel>author"`   // space: wp
+}
+
+// WPAuthor is a user of the site, as defined in the channel
+// Space: wp
+type WPAuthor struct {
+	ID          int    `xml:"author_id"`
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+	FirstName   string `xml:"author_first_name"`
+	LastName    string `xml:"author_last_name"`
+}
+
+// WPCategory is a category as defined in the channel
+// Space: wp
+type WPCategory struct {
+	ID          int    `xml:"term_id"`
+	Slug        string `xml:"category_nicename"`
+	Parent      string `xml:"category_parent"` // slug of the parent category
+	Name        string `xml:"cat_name"`
+	Description string `xml:"category_description"`
+}
+
+// WPTag is a tag as defined in the channel
+// Space: wp
+type WPTag struct {
+	ID          int    `xml:"term_id"`
+	Slug        string `xml:"tag_slug"`
+	Name        string `xml:"tag_name"`
+	Description string `xml:"tag_description"`
+}
+
+// Item is the place where posts, pages and attachments are represented
+type Item struct {
+	XMLName         xml.Name
+	Categories      []Category `xml:"category"`
+	Link            string     `xml:"link"`
+	PubDate         string     `xml:"pubDate"`
+	Title           string     `xml:"title"`
+	GUID            string     `xml:"guid"`
+	Encodeds        []Encoded  `xml:"encoded"`           // space: content / excerpt
+	Author          string     `xml:"creator"`           // space: dc
+	PostDate        string     `xml:"post_date"`         // space: wp
+	Slug            string     `xml:"post_name"`         // space: wp
+	PostDateGMT     string     `xml:"post_date_gmt"`     // space: wp
+	PostModified    string     `xml:"post_modified"`     // space: wp
+	PostModifiedGMT string     `xml:"post_modified_gmt"` // space: wp
+	PostMeta        []PostMeta `xml:"postmeta"`          // space: wp
+	Comments        []Comment  `xml:"comment"`           // space: wp
+	ID              int        `xml:"post_id"`           // space: wp
+	CommentStatus   string     `xml:"comment_status"`    // space: wp - may be open, closed
+	PostParent      int        `xml:"post_parent"`       // space: wp
+	MenuOrder       int        `xml:"menu_order"`        // space: wp
+	PostType        string     `xml:"post_type"`         // space: wp
+	Status          string     `xml:"status"`            // space: wp - may be publish, inherit, trash, draft ...
+	AttachmentURL   string     `xml:"attachment_url"`    // space: wp - the file of an attachment
+}
+
+// Meta is the value of the first postmeta of an item with the given key
+func (i Item) Meta(key string) (string, bool) {
+	for _, meta := range i.PostMeta {
+		if meta.MetaKey == key {
+			return meta.MetaValue.Value, true
+		}
+	}
+	return "", false
+}
+
+// MetaData is the value of the first postmeta of an item with the given key,
+// decoded if it is PHP serialized, see DecodeMeta
+func (i Item) MetaData(key string) (interface{}, bool) {
+	value, found := i.Meta(key)
+	if !found {
+		return nil, false
+	}
+	return DecodeMeta(value), true
+}
+
+// Category represents a category or tag
+type Category struct {
+	XMLName  xml.Name
+	Domain   string `xml:"domain,attr"` // values: 'category' / 'post_tag'
+	NiceName string `xml:"nicename,attr"`
+	Data     string `xml:",cdata"`
+}
+
+// Encoded represents the payload of an Item
+// may be in Space 'content' or 'excerpt'
+type Encoded struct {
+	XMLName xml.Name
+	Data    string `xml:",cdata"`
+}
+
+// PostMeta represents WordPress metadata
+// Space: wp
+type PostMeta struct {
+	XMLName   xml.Name
+	MetaKey   string    `xml:"meta_key"`
+	MetaValue MetaValue `xml:"meta_value"`
+}
+
+// MetaValue content of a PostMeta
+// Space: wp
+type MetaValue struct {
+	XMLName xml.Name
+	Value   string `xml:",cdata"`
+}
+
+// Comment represents a comment on the site, not an XML comment
+// the payload `Content` may contain embedded HTML, and is assumed to be
+// safe for inclusion into an HTML document
+// Space: wp
+type Comment struct {
+	XMLName        xml.Name
+	Approved       string        `xml:"comment_approved"` // may be: 1, trash
+	AuthorName     string        `xml:"comment_author"`
+	AuthorEmail    string        `xml:"comment_author_email"`
+	AuthorURL      string        `xml:"comment_author_url"`
+	Content        template.HTML `xml:"comment_content"`
+	ID             int           `xml:"comment_id"`
+	ParentID       int           `xml:"comment_parent"`
+	CommentDate    string        `xml:"comment_date"`
+	CommentDateGMT string        `xml:"comment_date_gmt"`
+}
+
+// CommentThread represents a comment and its descendants
+type CommentThread struct {
+	Comment
+	Children     []CommentThread
+	ChildrenHTML []template.HTML // this is just a convenience for rendering
+}